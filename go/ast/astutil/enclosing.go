@@ -322,6 +322,9 @@ func childrenOf(n ast.Node) []ast.Node {
 			children = append(children, n.Recv)
 		}
 		children = append(children, n.Name)
+		if n.Type.TypeParams != nil {
+			children = append(children, n.Type.TypeParams)
+		}
 		if n.Type.Params != nil {
 			children = append(children, n.Type.Params)
 		}
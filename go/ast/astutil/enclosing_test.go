@@ -193,3 +193,38 @@ func TestPathEnclosingInterval_Paths(t *testing.T) {
 		}
 	}
 }
+
+func TestPathEnclosingInterval_TypeParams(t *testing.T) {
+	const input = `
+package main
+func g[T any, U comparable](x T) U { var u U; return u }
+`
+	tests := []struct {
+		substr string // first occurrence of this string indicates interval
+		path   string // the pathToString(),exact of the expected path
+	}{
+		{"T any",
+			"[Field FieldList FuncDecl File],true"},
+		{"T",
+			"[Ident Field FieldList FuncDecl File],true"},
+		{"any",
+			"[Ident Field FieldList FuncDecl File],true"},
+		{"U comparable",
+			"[Field FieldList FuncDecl File],true"},
+		{"[T",
+			"[FieldList FuncDecl File],false"},
+	}
+	for _, test := range tests {
+		f, start, end := findInterval(t, new(token.FileSet), input, test.substr)
+		if f == nil {
+			continue
+		}
+
+		path, exact := astutil.PathEnclosingInterval(f, start, end)
+		if got := fmt.Sprintf("%s,%v", pathToString(path), exact); got != test.path {
+			t.Errorf("PathEnclosingInterval(%q): got %q, want %q",
+				test.substr, got, test.path)
+			continue
+		}
+	}
+}
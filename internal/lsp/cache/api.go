@@ -0,0 +1,162 @@
+package cache
+
+import (
+	"go/types"
+	"sort"
+	"strings"
+)
+
+// APIChangeKind kind of difference between two versions of an exported api
+type APIChangeKind int
+
+const (
+	// APIAdded symbol only in the new version
+	APIAdded APIChangeKind = iota
+	// APIRemoved symbol only in the old version
+	APIRemoved
+	// APIChanged symbol whose kind or type differ
+	APIChanged
+)
+
+func (k APIChangeKind) String() string {
+	switch k {
+	case APIAdded:
+		return "added"
+	case APIRemoved:
+		return "removed"
+	case APIChanged:
+		return "changed"
+	}
+	return "unknown"
+}
+
+// BreakingChange one difference between the exported api of two versions of a package
+type BreakingChange struct {
+	// Name symbol name, "T.M" for the fields and methods of T
+	Name     string
+	Kind     APIChangeKind
+	Breaking bool
+	// Old and New the symbol in each version, empty when added or removed
+	Old, New string
+}
+
+// APIBreakage compare the exported api of two versions of a package, sorted by name.
+// Removing or changing a symbol is breaking, so is adding a method to an existing interface
+// which may be implemented outside the package, adding any other symbol is not.
+func APIBreakage(old, new *pkg) []BreakingChange {
+	oldAPI, newAPI := exportedAPI(old), exportedAPI(new)
+
+	var changes []BreakingChange
+	for name, o := range oldAPI {
+		n, ok := newAPI[name]
+		switch {
+		case !ok:
+			changes = append(changes, BreakingChange{Name: name, Kind: APIRemoved, Breaking: true, Old: o.desc})
+		case o.desc != n.desc:
+			changes = append(changes, BreakingChange{Name: name, Kind: APIChanged, Breaking: true, Old: o.desc, New: n.desc})
+		}
+	}
+	for name, n := range newAPI {
+		if _, ok := oldAPI[name]; !ok {
+			// the methods of a new interface have no outside implementations to break
+			breaking := false
+			if n.implemented {
+				_, breaking = oldAPI[name[:strings.Index(name, ".")]]
+			}
+			changes = append(changes, BreakingChange{Name: name, Kind: APIAdded, Breaking: breaking, New: n.desc})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Name < changes[j].Name
+	})
+	return changes
+}
+
+// apiSymbol exported symbol of a package
+type apiSymbol struct {
+	// desc kind and type of the symbol, independent of the type-checking run
+	desc string
+	// implemented the symbol is a method of an interface other packages can implement
+	implemented bool
+}
+
+// exportedAPI map the exported package-level symbols of the package,
+// and the exported fields and methods of its exported types, by name
+func exportedAPI(p *pkg) map[string]apiSymbol {
+	api := make(map[string]apiSymbol)
+	if p == nil || p.types == nil {
+		return api
+	}
+
+	qf := types.RelativeTo(p.types)
+	scope := p.types.Scope()
+	for _, name := range scope.Names() {
+		obj := scope.Lookup(name)
+		if !obj.Exported() {
+			continue
+		}
+		api[name] = apiSymbol{desc: types.ObjectString(obj, qf)}
+
+		tn, ok := obj.(*types.TypeName)
+		if !ok {
+			continue
+		}
+		if sym, ok := typeSymbol(tn, qf); ok {
+			api[name] = sym
+		}
+		if st, ok := tn.Type().Underlying().(*types.Struct); ok {
+			for i := 0; i < st.NumFields(); i++ {
+				if f := st.Field(i); f.Exported() {
+					api[name+"."+f.Name()] = apiSymbol{desc: types.ObjectString(f, qf)}
+				}
+			}
+		}
+		mset := types.NewMethodSet(types.NewPointer(tn.Type()))
+		implemented := false
+		if types.IsInterface(tn.Type()) {
+			mset = types.NewMethodSet(tn.Type())
+			implemented = implementable(mset)
+		}
+		for i := 0; i < mset.Len(); i++ {
+			if m := mset.At(i).Obj(); m.Exported() {
+				api[name+"."+m.Name()] = apiSymbol{desc: types.ObjectString(m, qf), implemented: implemented}
+			}
+		}
+	}
+
+	return api
+}
+
+// typeSymbol describe a struct or interface type without its members, which are compared on their own,
+// so that adding a field or method is not reported as a change of the type too
+func typeSymbol(tn *types.TypeName, qf types.Qualifier) (apiSymbol, bool) {
+	if tn.IsAlias() {
+		return apiSymbol{}, false
+	}
+	desc := types.ObjectString(tn, qf)
+	under := types.TypeString(tn.Type().Underlying(), qf)
+	switch u := tn.Type().Underlying().(type) {
+	case *types.Struct:
+		return apiSymbol{desc: strings.TrimSuffix(desc, under) + "struct"}, true
+	case *types.Interface:
+		desc = strings.TrimSuffix(desc, under) + "interface"
+		// sealing an interface break its outside implementations
+		if !implementable(types.NewMethodSet(u)) {
+			desc += " with unexported methods"
+		}
+		return apiSymbol{desc: desc}, true
+	}
+	return apiSymbol{}, false
+}
+
+// implementable report whether other packages can implement the interface of method set mset,
+// an unexported method can only be implemented inside the package
+func implementable(mset *types.MethodSet) bool {
+	for i := 0; i < mset.Len(); i++ {
+		if !mset.At(i).Obj().Exported() {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,131 @@
+package cache
+
+import (
+	"testing"
+)
+
+func TestAPIBreakage(t *testing.T) {
+	old := newPackage(testLoad(t, "api", `package api
+
+func Removed() {}
+
+func Kept(s string) int { return len(s) }
+
+func Changed(n int) int { return n }
+`))
+	new := newPackage(testLoad(t, "api", `package api
+
+func Kept(s string) int { return len(s) }
+
+func Changed(n int64) int { return int(n) }
+
+func Added() {}
+`))
+
+	got := APIBreakage(old, new)
+	want := []struct {
+		name     string
+		kind     APIChangeKind
+		breaking bool
+	}{
+		{"Added", APIAdded, false},
+		{"Changed", APIChanged, true},
+		{"Removed", APIRemoved, true},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d changes %v, want %d", len(got), got, len(want))
+	}
+	for i, w := range want {
+		if g := got[i]; g.Name != w.name || g.Kind != w.kind || g.Breaking != w.breaking {
+			t.Errorf("change %d: got %s %s (breaking %v), want %s %s (breaking %v)", i, g.Name, g.Kind, g.Breaking, w.name, w.kind, w.breaking)
+		}
+	}
+}
+
+func TestAPIBreakageInterfaceMethod(t *testing.T) {
+	old := newPackage(testLoad(t, "api", `package api
+
+type Reader interface {
+	Read(p []byte) (int, error)
+}
+
+type Sealed interface {
+	Read(p []byte) (int, error)
+	sealed()
+}
+
+type Opened interface {
+	Open() error
+}
+
+type File struct{ Name string }
+
+func (File) Read(p []byte) (int, error) { return 0, nil }
+
+type ID int
+
+func (ID) String() string { return "" }
+`))
+	new := newPackage(testLoad(t, "api", `package api
+
+type Reader interface {
+	Read(p []byte) (int, error)
+	Close() error
+}
+
+type Sealed interface {
+	Read(p []byte) (int, error)
+	Close() error
+	sealed()
+}
+
+type Opened interface {
+	Open() error
+	opened()
+}
+
+type File struct {
+	Name string
+	Size int
+}
+
+func (File) Read(p []byte) (int, error) { return 0, nil }
+
+func (File) Close() error { return nil }
+
+type ID int64
+
+func (ID) String() string { return "" }
+
+type Writer interface {
+	Write(p []byte) (int, error)
+}
+`))
+
+	got := APIBreakage(old, new)
+	want := []struct {
+		name     string
+		kind     APIChangeKind
+		breaking bool
+	}{
+		{"File.Close", APIAdded, false},
+		{"File.Size", APIAdded, false},
+		{"ID", APIChanged, true},
+		// Outside implementations of Reader and Opened no longer implement them.
+		{"Opened", APIChanged, true},
+		{"Reader.Close", APIAdded, true},
+		// Sealed has no outside implementations.
+		{"Sealed.Close", APIAdded, false},
+		// Writer is new, so it has no implementations yet.
+		{"Writer", APIAdded, false},
+		{"Writer.Write", APIAdded, false},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d changes %v, want %d", len(got), got, len(want))
+	}
+	for i, w := range want {
+		if g := got[i]; g.Name != w.name || g.Kind != w.kind || g.Breaking != w.breaking {
+			t.Errorf("change %d: got %s %s (breaking %v), want %s %s (breaking %v)", i, g.Name, g.Kind, g.Breaking, w.name, w.kind, w.breaking)
+		}
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"go/token"
 	"go/types"
 	"sync"
+	"time"
 
 	"golang.org/x/tools/go/analysis"
 	"golang.org/x/tools/go/packages"
@@ -135,17 +136,21 @@ func (imp *importer) typeCheck(ctx context.Context, id packageID) (*pkg, error)
 			ph:        ph,
 		})
 	}
+	pkg.timings.Files = make([]FileTiming, len(phs))
 	for i, ph := range phs {
 		wg.Add(1)
 		go func(i int, ph source.ParseGoHandle) {
 			defer wg.Done()
 
+			start := time.Now()
 			files[i].file, files[i].err = ph.Parse(ctx)
+			pkg.timings.Files[i] = FileTiming{Filename: files[i].uri.Filename(), Start: start, End: time.Now()}
 		}(i, ph)
 	}
 	wg.Wait()
 
 	for _, f := range files {
+		f.setToken(imp.fset)
 		pkg.files = append(pkg.files, f)
 
 		if f.err != nil {
@@ -188,7 +193,9 @@ func (imp *importer) typeCheck(ctx context.Context, id packageID) (*pkg, error)
 	check := types.NewChecker(cfg, imp.fset, pkg.types, pkg.typesInfo)
 
 	// Ignore type-checking errors.
+	pkg.timings.CheckStart = time.Now()
 	check.Files(pkg.GetSyntax())
+	pkg.timings.CheckEnd = time.Now()
 
 	// Add every file in this package to our cache.
 	if err := imp.cachePackage(ctx, pkg, meta, mode); err != nil {
@@ -262,6 +269,11 @@ func (imp *importer) cachePerFile(gof *goFile, file *astFile, p *pkg) error {
 }
 
 func (c *cache) appendPkgError(pkg *pkg, err error) {
+	appendError(c.FileSet(), pkg, err)
+}
+
+// appendError record a parse or type error of the package, positions are resolved in fset
+func appendError(fset *token.FileSet, pkg *pkg, err error) {
 	if err == nil {
 		return
 	}
@@ -284,7 +296,7 @@ func (c *cache) appendPkgError(pkg *pkg, err error) {
 		}
 	case types.Error:
 		errs = append(errs, packages.Error{
-			Pos:  c.FileSet().Position(err.Pos).String(),
+			Pos:  fset.Position(err.Pos).String(),
 			Msg:  err.Msg,
 			Kind: packages.TypeError,
 		})
@@ -1,21 +1,43 @@
 package cache
 
 import (
+	"container/list"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"sort"
 	"sync"
+	"time"
 
 	"golang.org/x/tools/go/packages"
 	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
 )
 
 // GlobalCache global package cache for project
 type GlobalCache interface {
 	source.ICache
 	Add(pkg *packages.Package)
+	AddAll(pkgs []*packages.Package)
 	Put(pkg *pkg)
+	Pin(pkgPath string)
+	Unpin(pkgPath string)
+	Importers(pkgPath string) []string
+	Diagnostics(pkgPath string) []packages.Error
+	Find(pred func(source.Package) bool) source.Package
+	Stats() CacheStats
+	Paths() []string
+	Timings(pkgPath string) PackageTimings
+	ReanalyzePackage(fset *token.FileSet, pkgPath string) error
+	Variants(pkgPath string) []source.Package
 }
 
 type globalPackage struct {
 	pkg *pkg
+	// elem is the element of the package in the recently used list
+	elem *list.Element
 }
 
 type path2Package map[string]*globalPackage
@@ -23,31 +45,447 @@ type path2Package map[string]*globalPackage
 type globalCache struct {
 	mu      sync.RWMutex
 	pathMap path2Package
+	// variants hold the other packages with the path of a cached package, such as its test variant "p [p.test]",
+	// by path then package id
+	variants map[string]map[packageID]*pkg
+	pinned   map[string]bool
+	// importers index the cached packages importing each package
+	importers map[packagePath]map[packagePath]bool
+	// lru hold the package paths, most recently used first
+	lru *list.List
+	// maxPackages is the number of packages above which the least recently used are evicted, 0 means no limit
+	maxPackages int
+}
+
+// CacheOption configure a package cache
+type CacheOption func(c *globalCache)
+
+// WithMaxPackages limit the cache to n packages, evicting the least recently used beyond that
+func WithMaxPackages(n int) CacheOption {
+	return func(c *globalCache) {
+		c.maxPackages = n
+	}
 }
 
 // NewCache new a package cache
-func NewCache() *globalCache {
-	return &globalCache{pathMap: path2Package{}}
+func NewCache(opts ...CacheOption) *globalCache {
+	c := &globalCache{
+		pathMap:   path2Package{},
+		variants:  map[string]map[packageID]*pkg{},
+		pinned:    map[string]bool{},
+		importers: map[packagePath]map[packagePath]bool{},
+		lru:       list.New(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Len return the number of packages in the cache
+func (c *globalCache) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.pathMap)
+}
+
+// Paths return the sorted import paths of the cached packages,
+// it does not count as a use of the packages for eviction
+func (c *globalCache) Paths() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	paths := make([]string, 0, len(c.pathMap))
+	for path := range c.pathMap {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// CacheStats is a coarse measure of the memory retained by the cache
+type CacheStats struct {
+	Packages int
+	// Files is the number of syntax files
+	Files int
+	// Defs, Uses and Types are the number of entries of the type information maps,
+	// which grow with the size of the syntax trees
+	Defs  int
+	Uses  int
+	Types int
+}
+
+// Stats return the current footprint of the cache, to be logged periodically
+func (c *globalCache) Stats() CacheStats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var stats CacheStats
+	count := func(p *pkg) {
+		stats.Packages++
+		stats.Files += len(p.files)
+		if info := p.typesInfo; info != nil {
+			stats.Defs += len(info.Defs)
+			stats.Uses += len(info.Uses)
+			stats.Types += len(info.Types)
+		}
+	}
+	for path, p := range c.pathMap {
+		count(p.pkg)
+		for _, v := range c.variants[path] {
+			count(v)
+		}
+	}
+	return stats
+}
+
+// Pin exempt the package from eviction, such as the package of an open file.
+// A package may be pinned before it is added.
+func (c *globalCache) Pin(pkgPath string) {
+	c.mu.Lock()
+	c.pinned[pkgPath] = true
+	c.mu.Unlock()
+}
+
+// Unpin make the package evictable again
+func (c *globalCache) Unpin(pkgPath string) {
+	c.mu.Lock()
+	delete(c.pinned, pkgPath)
+	c.mu.Unlock()
+}
+
+// evictable report whether eviction may remove the package, the caller must hold the lock
+func (c *globalCache) evictable(pkgPath string) bool {
+	return !c.pinned[pkgPath]
+}
+
+// evict remove the least recently used packages until the cache fits its limit,
+// keeping pinned packages, packages imported by other cached packages and the package keep.
+// The caller must hold the lock.
+func (c *globalCache) evict(keep string) {
+	if c.maxPackages <= 0 {
+		return
+	}
+
+	for len(c.pathMap) > c.maxPackages {
+		var victim string
+		for e := c.lru.Back(); e != nil; e = e.Prev() {
+			pkgPath := e.Value.(string)
+			if pkgPath != keep && c.evictable(pkgPath) && !c.imported(pkgPath) {
+				victim = pkgPath
+				break
+			}
+		}
+		if victim == "" {
+			return
+		}
+		c.remove(victim)
+	}
+}
+
+// imported report whether a cached package import the package, the caller must hold the lock
+func (c *globalCache) imported(pkgPath string) bool {
+	return len(c.importers[packagePath(pkgPath)]) > 0
+}
+
+// Diagnostics return the list, parse and type errors of the cached package,
+// it does not count as a use of the package for eviction
+func (c *globalCache) Diagnostics(pkgPath string) []packages.Error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	p := c.pathMap[pkgPath]
+	if p == nil {
+		return nil
+	}
+	return p.pkg.GetErrors()
+}
+
+// Timings return the time spent parsing and type-checking the cached package,
+// it does not count as a use of the package for eviction
+func (c *globalCache) Timings(pkgPath string) PackageTimings {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	p := c.pathMap[pkgPath]
+	if p == nil {
+		return PackageTimings{}
+	}
+	return p.pkg.timings
+}
+
+// ReanalyzePackage parse again the files of the cached package and type-check them against the cached imports,
+// for instance after the files changed on disk, and replace the package, recording the time spent in its timings.
+// fset must be the file set the package and its cached imports were parsed in, so that the positions of the
+// imported objects are comparable with those of the files. Importers of the package are not type-checked again.
+func (c *globalCache) ReanalyzePackage(fset *token.FileSet, pkgPath string) error {
+	c.mu.RLock()
+	var old *pkg
+	if gp := c.pathMap[pkgPath]; gp != nil {
+		old = gp.pkg
+	}
+	cachedImports := map[packagePath]*pkg{}
+	if old != nil {
+		for importPath := range old.imports {
+			if ip := c.pathMap[string(importPath)]; ip != nil {
+				cachedImports[importPath] = ip.pkg
+			}
+		}
+	}
+	c.mu.RUnlock()
+
+	if old == nil {
+		return &source.PackageNotFoundError{Path: pkgPath}
+	}
+	if !inFileSet(fset, old) {
+		return fmt.Errorf("package %s was not parsed in the file set", pkgPath)
+	}
+	for importPath, ip := range cachedImports {
+		if !inFileSet(fset, ip) {
+			return fmt.Errorf("import %s of package %s was not parsed in the file set", importPath, pkgPath)
+		}
+	}
+
+	p := &pkg{
+		id:         old.id,
+		pkgPath:    old.pkgPath,
+		imports:    make(map[packagePath]*pkg),
+		typesSizes: old.typesSizes,
+		typesInfo: &types.Info{
+			Types:      make(map[ast.Expr]types.TypeAndValue),
+			Defs:       make(map[*ast.Ident]types.Object),
+			Uses:       make(map[*ast.Ident]types.Object),
+			Implicits:  make(map[ast.Node]types.Object),
+			Selections: make(map[*ast.SelectorExpr]*types.Selection),
+			Scopes:     make(map[ast.Node]*types.Scope),
+		},
+	}
+	for _, f := range old.files {
+		if f.tok == nil {
+			continue
+		}
+		filename := f.tok.Name()
+		start := time.Now()
+		file, err := parser.ParseFile(fset, filename, nil, parser.ParseComments)
+		p.timings.Files = append(p.timings.Files, FileTiming{Filename: filename, Start: start, End: time.Now()})
+		appendError(fset, p, err)
+		if file == nil {
+			continue
+		}
+		af := &astFile{uri: span.FileURI(filename), file: file, err: err}
+		af.setToken(fset)
+		p.files = append(p.files, af)
+	}
+
+	// Prefer the cached imports, which may have been reanalyzed too, to those the package was checked against.
+	imports := packageImporter{}
+	if old.types != nil {
+		for _, imp := range old.types.Imports() {
+			imports[imp.Path()] = imp
+		}
+	}
+	for importPath, ip := range cachedImports {
+		imports[string(importPath)] = ip.types
+		p.imports[importPath] = ip
+	}
+	cfg := &types.Config{
+		Importer: imports,
+		Error: func(err error) {
+			appendError(fset, p, err)
+		},
+	}
+	p.timings.CheckStart = time.Now()
+	p.types, _ = cfg.Check(pkgPath, fset, p.GetSyntax(), p.typesInfo)
+	p.timings.CheckEnd = time.Now()
+
+	c.Put(p)
+	return nil
+}
+
+// inFileSet report whether the files of the package were parsed in fset
+func inFileSet(fset *token.FileSet, p *pkg) bool {
+	for _, f := range p.files {
+		if f.tok != nil && fset.File(f.base) != f.tok {
+			return false
+		}
+	}
+	return true
+}
+
+// packageImporter import the packages by path
+type packageImporter map[string]*types.Package
+
+func (imp packageImporter) Import(pkgPath string) (*types.Package, error) {
+	if pkgPath == "unsafe" {
+		return types.Unsafe, nil
+	}
+	if p := imp[pkgPath]; p != nil {
+		return p, nil
+	}
+	return nil, &source.PackageNotFoundError{Path: pkgPath}
+}
+
+// Importers return the sorted paths of the cached packages directly importing the package
+func (c *globalCache) Importers(pkgPath string) []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var importers []string
+	for importer := range c.importers[packagePath(pkgPath)] {
+		importers = append(importers, string(importer))
+	}
+	sort.Strings(importers)
+	return importers
+}
+
+// Variants return the packages cached with the path of the package other than the one Get return,
+// such as its test variant, sorted by package id. It does not count as a use of the package for eviction
+func (c *globalCache) Variants(pkgPath string) []source.Package {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	variants := make([]*pkg, 0, len(c.variants[pkgPath]))
+	for _, v := range c.variants[pkgPath] {
+		variants = append(variants, v)
+	}
+	sort.Slice(variants, func(i, j int) bool {
+		return variants[i].id < variants[j].id
+	})
+
+	pkgs := make([]source.Package, len(variants))
+	for i, v := range variants {
+		pkgs[i] = v
+	}
+	return pkgs
+}
+
+// index record the package as an importer of each of its imports, the caller must hold the lock
+func (c *globalCache) index(p *pkg) {
+	for importPath := range p.imports {
+		if c.importers[importPath] == nil {
+			c.importers[importPath] = map[packagePath]bool{}
+		}
+		c.importers[importPath][p.pkgPath] = true
+	}
+}
+
+// unindex undo index, the caller must hold the lock
+func (c *globalCache) unindex(p *pkg) {
+	for importPath := range p.imports {
+		delete(c.importers[importPath], p.pkgPath)
+		if len(c.importers[importPath]) == 0 {
+			delete(c.importers, importPath)
+		}
+	}
+}
+
+// indexPath index the packages cached with the path, which share their importer entries,
+// the caller must hold the lock
+func (c *globalCache) indexPath(pkgPath string) {
+	if p := c.pathMap[pkgPath]; p != nil {
+		c.index(p.pkg)
+	}
+	for _, v := range c.variants[pkgPath] {
+		c.index(v)
+	}
+}
+
+// remove remove the package and its variants from the cache, the caller must hold the lock
+func (c *globalCache) remove(pkgPath string) {
+	if p := c.pathMap[pkgPath]; p != nil {
+		c.lru.Remove(p.elem)
+		c.unindex(p.pkg)
+		delete(c.pathMap, pkgPath)
+	}
+	for _, v := range c.variants[pkgPath] {
+		c.unindex(v)
+	}
+	delete(c.variants, pkgPath)
 }
 
 // Put put package into global cache
 func (c *globalCache) Put(pkg *pkg) {
 	c.mu.Lock()
 	c.put(pkg)
+	c.evict(pkg.GetTypes().Path())
 	c.mu.Unlock()
 }
 
+// put cache the package in place of its path, unless it is a variant of the package cached there,
+// the caller must hold the lock.
+// A package is the one its variants derive from when its id is its path, the variants are cached aside.
 func (c *globalCache) put(pkg *pkg) {
 	pkgPath := pkg.GetTypes().Path()
-	p := &globalPackage{pkg: pkg}
+	if old := c.pathMap[pkgPath]; old != nil {
+		if old.pkg.id != pkg.id && string(pkg.id) != pkgPath {
+			c.putVariant(pkgPath, pkg)
+			return
+		}
+		c.lru.Remove(old.elem)
+		c.unindex(old.pkg)
+		if old.pkg.id != pkg.id {
+			// the package take the place of a variant cached before it
+			c.putVariant(pkgPath, old.pkg)
+		}
+	}
+	p := &globalPackage{pkg: pkg, elem: c.lru.PushFront(pkgPath)}
 	c.pathMap[pkgPath] = p
+	c.indexPath(pkgPath)
 }
 
-// Get get package by package import path from global cache
+// putVariant cache the package aside the package of the same path, the caller must hold the lock
+func (c *globalCache) putVariant(pkgPath string, p *pkg) {
+	if c.variants[pkgPath] == nil {
+		c.variants[pkgPath] = map[packageID]*pkg{}
+	}
+	if old := c.variants[pkgPath][p.id]; old != nil {
+		c.unindex(old)
+	}
+	c.variants[pkgPath][p.id] = p
+	c.indexPath(pkgPath)
+}
+
+// cached return the cached package of the path with the id, the caller must hold the lock
+func (c *globalCache) cached(pkgPath string, id packageID) *pkg {
+	if p := c.pathMap[pkgPath]; p != nil && p.pkg.id == id {
+		return p.pkg
+	}
+	return c.variants[pkgPath][id]
+}
+
+// Delete remove the package and, transitively, every cached package that import it,
+// since their type information is stale. It return the sorted paths of the removed packages.
+func (c *globalCache) Delete(pkgPath string) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.pathMap[pkgPath] == nil {
+		return nil
+	}
+
+	deleted := []string{pkgPath}
+	seen := map[string]bool{pkgPath: true}
+	for i := 0; i < len(deleted); i++ {
+		for importer := range c.importers[packagePath(deleted[i])] {
+			if path := string(importer); !seen[path] {
+				seen[path] = true
+				deleted = append(deleted, path)
+			}
+		}
+	}
+	for _, path := range deleted {
+		c.remove(path)
+	}
+
+	sort.Strings(deleted)
+	return deleted
+}
+
+// Get get package by package import path from global cache, and mark it as recently used
 func (c *globalCache) Get(pkgPath string) *pkg {
-	c.mu.RLock()
+	c.mu.Lock()
 	p := c.get(pkgPath)
-	c.mu.RUnlock()
+	c.mu.Unlock()
 	return p
 }
 
@@ -58,20 +496,10 @@ func (c *globalCache) get(pkgPath string) *pkg {
 		return nil
 	}
 
+	c.lru.MoveToFront(p.elem)
 	return p.pkg
 }
 
-func (c *globalCache) getGlobalPackage(pkgPath string) *globalPackage {
-	c.mu.RLock()
-	p := c.pathMap[pkgPath]
-	c.mu.RUnlock()
-	if p == nil {
-		return nil
-	}
-
-	return p
-}
-
 // Walk walk the global package cache
 func (c *globalCache) Walk(walkFunc source.WalkFunc) {
 	c.walk(walkFunc)
@@ -87,23 +515,73 @@ func (c *globalCache) walk(walkFunc source.WalkFunc) {
 	}
 }
 
-func (c *globalCache) Add(pkg *packages.Package) {
-	c.recursiveAdd(pkg, nil)
+// Find return the first cached package matching pred, or nil if none match.
+// Packages are visited in no particular order, so if several packages match,
+// any of them may be returned.
+func (c *globalCache) Find(pred func(source.Package) bool) source.Package {
+	var found source.Package
+	c.walk(func(p source.Package) bool {
+		if pred(p) {
+			found = p
+			return true
+		}
+		return false
+	})
+	return found
+}
+
+func (c *globalCache) Add(p *packages.Package) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.recursiveAdd(p, nil, map[string]*pkg{}, nil)
+	// Evict once the whole import graph is linked, so that imports are not evicted before their importer is added.
+	c.evict(p.PkgPath)
+}
+
+// AddAll add the packages and their imports under a single lock acquisition,
+// which is cheaper than calling Add for each when loading a whole workspace
+func (c *globalCache) AddAll(pkgs []*packages.Package) {
+	c.addTimed(pkgs, nil)
 }
 
-func (c *globalCache) recursiveAdd(pkg *packages.Package, parent *pkg) {
-	if p := c.getGlobalPackage(pkg.PkgPath); p != nil {
+// addTimed is AddAll for packages loaded with the ParseFile of timer, recording the time spent parsing their files
+func (c *globalCache) addTimed(pkgs []*packages.Package, timer *parseTimer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	adding := map[string]*pkg{}
+	for _, pkg := range pkgs {
+		c.recursiveAdd(pkg, nil, adding, timer)
+	}
+	c.evict("")
+}
+
+// recursiveAdd add the package and its imports, the caller must hold the lock.
+// adding hold the packages whose imports are being added by id, so that an import cycle link back to
+// the package in progress instead of recursing forever.
+func (c *globalCache) recursiveAdd(pkg *packages.Package, parent *pkg, adding map[string]*pkg, timer *parseTimer) {
+	if p := c.cached(pkg.PkgPath, packageID(pkg.ID)); p != nil {
+		if parent != nil {
+			parent.addImport(p)
+		}
+		return
+	}
+	if p := adding[pkg.ID]; p != nil {
 		if parent != nil {
-			parent.addImport(p.pkg)
+			parent.addImport(p)
 		}
 		return
 	}
 
 	p := newPackage(pkg)
+	p.timings = timer.timings(pkg)
 
+	adding[pkg.ID] = p
 	for _, ip := range pkg.Imports {
-		c.recursiveAdd(ip, p)
+		c.recursiveAdd(ip, p, adding, timer)
 	}
+	delete(adding, pkg.ID)
 
 	c.put(p)
 
@@ -128,7 +606,9 @@ func newPackage(p *packages.Package) *pkg {
 func createAstFiles(p *packages.Package) []*astFile {
 	var astFiles []*astFile
 	for _, file := range p.Syntax {
-		astFiles = append(astFiles, &astFile{file: file})
+		f := &astFile{file: file}
+		f.setToken(p.Fset)
+		astFiles = append(astFiles, f)
 	}
 
 	return astFiles
@@ -136,5 +616,5 @@ func createAstFiles(p *packages.Package) []*astFile {
 
 // addImport add import package
 func (p *pkg) addImport(ip *pkg) {
-	p.imports[p.pkgPath] = ip
+	p.imports[ip.pkgPath] = ip
 }
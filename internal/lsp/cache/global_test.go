@@ -0,0 +1,608 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/internal/lsp/source"
+)
+
+func TestPin(t *testing.T) {
+	c := NewCache()
+	c.Pin("a")
+	c.Pin("b")
+	c.Unpin("b")
+
+	if c.evictable("a") {
+		t.Error("pinned package a is evictable")
+	}
+	if !c.evictable("b") {
+		t.Error("unpinned package b is not evictable")
+	}
+}
+
+func TestAddImports(t *testing.T) {
+	a := testLoad(t, "a", "package a\n\nconst A = 1\n")
+	b := testLoad(t, "b", "package b\n\nconst B = 2\n")
+	c := testLoad(t, "c", "package c\n\nconst C = 3\n")
+	main := testLoad(t, "main", `package main
+
+import (
+	"a"
+	"b"
+	"c"
+)
+
+const Sum = a.A + b.B + c.C
+`, a, b, c)
+
+	cache := NewCache()
+	cache.Add(main)
+
+	p := cache.Get("main")
+	if p == nil {
+		t.Fatal("package main was not added")
+	}
+	for _, path := range []string{"a", "b", "c"} {
+		imp := p.GetImport(path)
+		if imp == nil {
+			t.Errorf("GetImport(%q) = nil", path)
+			continue
+		}
+		if got := imp.PkgPath(); got != path {
+			t.Errorf("GetImport(%q) returned package %s", path, got)
+		}
+	}
+	if imp := p.GetImport("main"); imp != nil {
+		t.Errorf("GetImport(\"main\") returned package %s, want nil", imp.PkgPath())
+	}
+}
+
+func TestAddImportCycle(t *testing.T) {
+	// go/packages may report a cycle for erroneous code, link the packages a -> b -> c -> a by hand.
+	a := testLoad(t, "a", "package a\n")
+	b := testLoad(t, "b", "package b\n")
+	c := testLoad(t, "c", "package c\n")
+	a.Imports["b"] = b
+	b.Imports["c"] = c
+	c.Imports["a"] = a
+
+	cache := NewCache()
+	cache.Add(a)
+	checkCached(t, cache, "a", "b", "c")
+
+	for _, edge := range [][2]string{{"a", "b"}, {"b", "c"}, {"c", "a"}} {
+		imp := cache.Get(edge[0]).GetImport(edge[1])
+		if imp == nil || imp.(*pkg) != cache.Get(edge[1]) {
+			t.Errorf("package %s does not import the cached package %s", edge[0], edge[1])
+		}
+	}
+	if got := cache.Importers("a"); len(got) != 1 || got[0] != "c" {
+		t.Errorf("Importers(\"a\") = %v, want [c]", got)
+	}
+
+	self := testLoad(t, "self", "package self\n")
+	self.Imports["self"] = self
+	cache.AddAll([]*packages.Package{self})
+	if p := cache.Get("self"); p == nil || p.GetImport("self") != p {
+		t.Errorf("package importing itself was not added with its import")
+	}
+}
+
+// testPkg builds a cached package path with no imports.
+func testPkg(t *testing.T, path string) *pkg {
+	return newPackage(testLoad(t, path, "package "+path+"\n"))
+}
+
+// checkCached checks that the cache holds exactly the packages paths.
+func checkCached(t *testing.T, c *globalCache, paths ...string) {
+	t.Helper()
+
+	if c.Len() != len(paths) {
+		t.Errorf("cache holds %d packages, want %d", c.Len(), len(paths))
+	}
+	for _, path := range paths {
+		if c.pathMap[path] == nil {
+			t.Errorf("package %s was evicted", path)
+		}
+	}
+}
+
+func TestLRUEviction(t *testing.T) {
+	c := NewCache(WithMaxPackages(2))
+	c.Put(testPkg(t, "a"))
+	c.Put(testPkg(t, "b"))
+	checkCached(t, c, "a", "b")
+
+	// Using a makes b the least recently used package.
+	c.Get("a")
+	c.Put(testPkg(t, "c"))
+	checkCached(t, c, "a", "c")
+
+	c.Put(testPkg(t, "d"))
+	checkCached(t, c, "c", "d")
+}
+
+func TestEvictionKeepsPinned(t *testing.T) {
+	c := NewCache(WithMaxPackages(1))
+	c.Pin("a")
+	c.Put(testPkg(t, "a"))
+	c.Put(testPkg(t, "b"))
+	c.Put(testPkg(t, "c"))
+	checkCached(t, c, "a", "c")
+
+	c.Unpin("a")
+	c.Put(testPkg(t, "d"))
+	checkCached(t, c, "d")
+}
+
+func TestEvictionKeepsImports(t *testing.T) {
+	a := testLoad(t, "a", "package a\n\nconst A = 1\n")
+	b := testLoad(t, "b", "package b\n\nconst B = 2\n")
+	main := testLoad(t, "main", `package main
+
+import (
+	"a"
+	"b"
+)
+
+const Sum = a.A + b.B
+`, a, b)
+
+	c := NewCache(WithMaxPackages(2))
+	c.Add(main)
+	checkCached(t, c, "main", "a", "b")
+
+	// The imports of main, although used less recently, are only evicted
+	// once main itself is; then one of them must go too.
+	c.Put(testPkg(t, "other"))
+	if c.Len() != 2 || c.pathMap["main"] != nil || c.pathMap["other"] == nil {
+		t.Errorf("got %d packages, want other and one import of main", c.Len())
+	}
+}
+
+func TestDelete(t *testing.T) {
+	// leaf is imported by left and right, both imported by top, and other
+	// only imports right.
+	leaf := testLoad(t, "leaf", "package leaf\n\nconst V = 1\n")
+	left := testLoad(t, "left", "package left\n\nimport \"leaf\"\n\nconst V = leaf.V\n", leaf)
+	right := testLoad(t, "right", "package right\n\nimport \"leaf\"\n\nconst V = leaf.V\n", leaf)
+	top := testLoad(t, "top", "package top\n\nimport (\n\t\"left\"\n\t\"right\"\n)\n\nconst V = left.V + right.V\n", left, right)
+	other := testLoad(t, "other", "package other\n\nimport \"right\"\n\nconst V = right.V\n", right)
+	unrelated := testLoad(t, "unrelated", "package unrelated\n")
+
+	for _, test := range []struct {
+		path, deleted string
+		kept          []string
+	}{
+		{"leaf", "leaf left other right top", []string{"unrelated"}},
+		{"left", "left top", []string{"leaf", "right", "other", "unrelated"}},
+		{"right", "other right top", []string{"leaf", "left", "unrelated"}},
+		{"top", "top", []string{"leaf", "left", "right", "other", "unrelated"}},
+		{"missing", "", []string{"leaf", "left", "right", "top", "other", "unrelated"}},
+	} {
+		c := NewCache()
+		c.Add(top)
+		c.Add(other)
+		c.Add(unrelated)
+
+		if got := strings.Join(c.Delete(test.path), " "); got != test.deleted {
+			t.Errorf("Delete(%q) = %q, want %q", test.path, got, test.deleted)
+		}
+		checkCached(t, c, test.kept...)
+	}
+}
+
+func TestImporters(t *testing.T) {
+	a := testLoad(t, "a", "package a\n\nconst V = 1\n")
+	b := testLoad(t, "b", "package b\n\nimport \"a\"\n\nconst V = a.V\n", a)
+	c := testLoad(t, "c", "package c\n\nimport \"b\"\n\nconst V = b.V\n", b)
+
+	cache := NewCache()
+	cache.Add(c)
+
+	for path, want := range map[string]string{"a": "b", "b": "c", "c": ""} {
+		if got := strings.Join(cache.Importers(path), " "); got != want {
+			t.Errorf("Importers(%q) = %q, want %q", path, got, want)
+		}
+	}
+
+	// Transitive importers are found by composing lookups.
+	var transitive []string
+	for queue := cache.Importers("a"); len(queue) > 0; queue = queue[1:] {
+		transitive = append(transitive, queue[0])
+		queue = append(queue, cache.Importers(queue[0])...)
+	}
+	if got := strings.Join(transitive, " "); got != "b c" {
+		t.Errorf("transitive importers of a = %q, want \"b c\"", got)
+	}
+
+	cache.Delete("b")
+	if got := cache.Importers("a"); len(got) != 0 {
+		t.Errorf("Importers(\"a\") = %q after deleting b, want none", got)
+	}
+}
+
+func TestAddAll(t *testing.T) {
+	shared := testLoad(t, "shared", "package shared\n\nconst V = 1\n")
+	a := testLoad(t, "a", "package a\n\nimport \"shared\"\n\nconst V = shared.V\n", shared)
+	b := testLoad(t, "b", "package b\n\nimport \"shared\"\n\nconst V = shared.V\n", shared)
+
+	c := NewCache()
+	c.AddAll([]*packages.Package{a, b, shared})
+	checkCached(t, c, "a", "b", "shared")
+
+	// Both roots must link to the single cached copy of their shared import.
+	want := c.Get("shared")
+	for _, path := range []string{"a", "b"} {
+		if got := c.Get(path).GetImport("shared"); got != want {
+			t.Errorf("%s imports a different copy of shared", path)
+		}
+	}
+	if got := strings.Join(c.Importers("shared"), " "); got != "a b" {
+		t.Errorf("Importers(\"shared\") = %q, want \"a b\"", got)
+	}
+}
+
+// benchGraph builds n packages, each importing the previous ones up to a depth of 8.
+// They are not type-checked, the cache only needs their paths and imports.
+func benchGraph(n int) []*packages.Package {
+	pkgs := make([]*packages.Package, n)
+	for i := range pkgs {
+		path := fmt.Sprintf("p%d", i)
+		p := &packages.Package{
+			ID:      path,
+			PkgPath: path,
+			Types:   types.NewPackage(path, path),
+			Imports: make(map[string]*packages.Package),
+		}
+		for j := i - 1; j >= 0 && j >= i-8; j-- {
+			p.Imports[pkgs[j].PkgPath] = pkgs[j]
+		}
+		pkgs[i] = p
+	}
+	return pkgs
+}
+
+func BenchmarkAdd(b *testing.B) {
+	pkgs := benchGraph(1000)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c := NewCache()
+		for _, p := range pkgs {
+			c.Add(p)
+		}
+	}
+}
+
+func BenchmarkAddAll(b *testing.B) {
+	pkgs := benchGraph(1000)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		NewCache().AddAll(pkgs)
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	good := testLoad(t, "good", "package good\n\nconst V = 1\n")
+	bad := testLoad(t, "bad", "package bad\n\nimport \"good\"\n\nvar V string = good.V\n", good)
+
+	c := NewCache()
+	c.Add(bad)
+
+	errs := c.Diagnostics("bad")
+	if len(errs) != 1 || errs[0].Kind != packages.TypeError || !strings.Contains(errs[0].Msg, "cannot use good.V") {
+		t.Errorf("Diagnostics(\"bad\") = %v, want the type error of V", errs)
+	}
+	if errs := c.Diagnostics("good"); len(errs) != 0 {
+		t.Errorf("Diagnostics(\"good\") = %v, want none", errs)
+	}
+	if errs := c.Diagnostics("missing"); errs != nil {
+		t.Errorf("Diagnostics(\"missing\") = %v, want nil", errs)
+	}
+}
+
+func TestFind(t *testing.T) {
+	a := testLoad(t, "a", "package a\n\nconst V = 1\n")
+	b := testLoad(t, "b", "package b\n\nimport \"a\"\n\nvar V string = a.V\n", a)
+
+	c := NewCache()
+	c.Add(b)
+
+	if p := c.Find(func(p source.Package) bool { return len(p.GetErrors()) > 0 }); p == nil || p.PkgPath() != "b" {
+		t.Errorf("Find(has errors) = %v, want b", p)
+	}
+	if p := c.Find(func(p source.Package) bool { return p.PkgPath() == "missing" }); p != nil {
+		t.Errorf("Find(missing) = %s, want nil", p.PkgPath())
+	}
+}
+
+// libSrc is the file of package lib, and libTestSrc the file its test
+// variant adds, which tests Foo and calls Sum.
+const (
+	libSrc     = "package lib\n\nfunc Foo() {}\n\nfunc Bar() {}\n\nfunc Sum(a, b int) int { return a + b }\n"
+	libTestSrc = "package lib\n\nimport \"testing\"\n\nfunc TestFoo(t *testing.T) { Foo() }\n\nfunc testSum() int { return Sum(1, 2) }\n"
+)
+
+// testVariant loads the test variant of package lib, whose files are those
+// of lib and its test file.
+func testVariant(t *testing.T, lib *packages.Package) *packages.Package {
+	t.Helper()
+
+	f, err := parser.ParseFile(testFset, "/src/lib/lib_test.go", libTestSrc, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := testCheck("lib", append([]*ast.File{lib.Syntax[0]}, f))
+	p.ID = "lib [lib.test]"
+	return p
+}
+
+func TestVariants(t *testing.T) {
+	lib := testLoad(t, "lib", libSrc)
+	variant := testVariant(t, lib)
+
+	// The test variant shares the path of the package, whichever is cached first.
+	for _, order := range [][]*packages.Package{{lib, variant}, {variant, lib}} {
+		c := NewCache()
+		for _, p := range order {
+			c.Add(p)
+		}
+
+		checkCached(t, c, "lib")
+		if got := c.Get("lib"); got == nil || got.id != "lib" {
+			t.Fatalf("Get(\"lib\") = %v, want the package lib", got)
+		}
+		variants := c.Variants("lib")
+		if len(variants) != 1 || variants[0].(*pkg).id != "lib [lib.test]" {
+			t.Fatalf("Variants(\"lib\") = %v, want the test variant", variants)
+		}
+		if stats := c.Stats(); stats.Packages != 2 {
+			t.Errorf("stats count %d packages, want 2", stats.Packages)
+		}
+
+		// A position in the test file is only found in the test variant.
+		offset := strings.Index(libTestSrc, "return Sum(1, 2)") + len("return ")
+		pos := testFset.File(variant.Syntax[1].Pos()).Pos(offset)
+		def, err := source.VariantDefinition(c, c.Get("lib"), testFset, pos)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := testFset.Position(def.Object.Pos()); got.Filename != "/src/lib/lib.go" || got.Line != 7 {
+			t.Errorf("Sum defined at %v, want /src/lib/lib.go:7", got)
+		}
+
+		c.Delete("lib")
+		if variants := c.Variants("lib"); len(variants) != 0 {
+			t.Errorf("Variants(\"lib\") = %v after deleting lib, want none", variants)
+		}
+	}
+}
+
+// TestVariantsSearched checks that the searches of the test files of a
+// package see its test variant, which the cache keeps out of its walk.
+func TestVariantsSearched(t *testing.T) {
+	lib := testLoad(t, "lib", libSrc)
+	c := NewCache()
+	c.Add(lib)
+	c.Add(testVariant(t, lib))
+
+	names := func(symbols []source.Symbol) string {
+		var names []string
+		for _, s := range symbols {
+			names = append(names, s.Name)
+		}
+		return strings.Join(names, " ")
+	}
+	if got := names(source.TestCoverageGaps(c, testFset, "lib")); got != "Bar Sum" {
+		t.Errorf("TestCoverageGaps = %q, want \"Bar Sum\"", got)
+	}
+	if got := names(source.UnreferencedExports(c, testFset)); got != "Bar" {
+		t.Errorf("UnreferencedExports = %q, want \"Bar\"", got)
+	}
+
+	foo := c.Get("lib").GetTypes().Scope().Lookup("Foo")
+	var refs []string
+	for _, loc := range source.ObjectReferences(c, testFset, foo, false) {
+		refs = append(refs, fmt.Sprintf("%s:%d", filepath.Base(loc.Span.URI().Filename()), loc.Span.Start().Line()))
+	}
+	if got := strings.Join(refs, " "); got != "lib_test.go:5" {
+		t.Errorf("references to Foo = %q, want \"lib_test.go:5\"", got)
+	}
+}
+
+func TestSyntaxRanges(t *testing.T) {
+	p := testPkg(t, "a")
+
+	ranges := p.GetSyntaxRanges()
+	if len(ranges) != 1 {
+		t.Fatalf("got %d ranges, want 1", len(ranges))
+	}
+	tok := testFset.File(ranges[0].File.Pos())
+	if int(ranges[0].Start) != tok.Base() || int(ranges[0].End) != tok.Base()+tok.Size() {
+		t.Errorf("got range [%d, %d), want [%d, %d)", ranges[0].Start, ranges[0].End, tok.Base(), tok.Base()+tok.Size())
+	}
+}
+
+func TestStats(t *testing.T) {
+	c := NewCache()
+	if stats := c.Stats(); stats != (CacheStats{}) {
+		t.Errorf("empty cache stats = %+v, want zero", stats)
+	}
+
+	a := testLoad(t, "a", "package a\n\nconst V = 1\n")
+	c.Add(a)
+	before := c.Stats()
+	if before.Packages != 1 || before.Files != 1 || before.Defs == 0 {
+		t.Errorf("stats after adding a = %+v, want 1 package and file with definitions", before)
+	}
+
+	b := testLoad(t, "b", "package b\n\nimport \"a\"\n\nfunc F() int { return a.V + 1 }\n", a)
+	c.Add(b)
+	after := c.Stats()
+	if after.Packages != 2 || after.Files != 2 || after.Defs <= before.Defs || after.Uses <= before.Uses || after.Types <= before.Types {
+		t.Errorf("stats after adding b = %+v, want more than %+v", after, before)
+	}
+}
+
+func TestReanalyzePackageTimings(t *testing.T) {
+	dir, err := ioutil.TempDir("", "timings")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "b.go")
+	src := "package b\n\nimport (\n\t\"a\"\n\t\"strings\"\n)\n\nfunc F() string { return strings.Repeat(\"x\", a.V) }\n"
+	a := testLoad(t, "a", "package a\n\nconst V = 1\n")
+	c := NewCache()
+	c.Add(testLoadFile(t, filename, "b", src, a))
+
+	if timings := c.Timings("b"); len(timings.Files) != 0 || timings.Check() != 0 {
+		t.Errorf("timings of a package from go/packages = %+v, want zero", timings)
+	}
+
+	if err := ioutil.WriteFile(filename, []byte(src+"\nfunc G() int { return a.V }\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ReanalyzePackage(testFset, "b"); err != nil {
+		t.Fatal(err)
+	}
+
+	p := c.Get("b")
+	if errs := p.GetErrors(); len(errs) != 0 {
+		t.Fatalf("reanalyzed package has errors %v", errs)
+	}
+	if p.GetTypes().Scope().Lookup("G") == nil {
+		t.Errorf("reanalyzed package does not define G")
+	}
+	if p.GetImport("a") == nil {
+		t.Errorf("reanalyzed package lost its import of a")
+	}
+
+	timings := c.Timings("b")
+	if len(timings.Files) != 1 || timings.Files[0].Filename != filename {
+		t.Fatalf("file timings = %+v, want one for %s", timings.Files, filename)
+	}
+	f := timings.Files[0]
+	if f.Start.IsZero() || f.End.Before(f.Start) || timings.CheckStart.Before(f.End) || timings.CheckEnd.Before(timings.CheckStart) {
+		t.Errorf("timings = %+v, want parsing then type-checking in order", timings)
+	}
+	if slowest, ok := timings.Slowest(); !ok || slowest != f {
+		t.Errorf("Slowest() = %+v, %v, want %+v", slowest, ok, f)
+	}
+
+	// The positions of the cached packages are not comparable with those of another file set.
+	if err := c.ReanalyzePackage(token.NewFileSet(), "b"); err == nil {
+		t.Errorf("ReanalyzePackage in another file set succeeded, want an error")
+	}
+	if c.Get("b") != p {
+		t.Errorf("ReanalyzePackage in another file set replaced the package")
+	}
+
+	if err := c.ReanalyzePackage(testFset, "missing"); !errors.Is(err, &source.PackageNotFoundError{}) {
+		t.Errorf("ReanalyzePackage(\"missing\") = %v, want a PackageNotFoundError", err)
+	}
+}
+
+func TestAddTimed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "timings")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"go.mod": "module example.com/timed\n",
+		"a/a.go": "package a\n\nconst V = 1\n",
+		"b/b.go": "package b\n\nimport \"example.com/timed/a\"\n\nconst V = a.V\n",
+		"b/c.go": "package b\n\nconst W = V\n",
+	}
+	for name, src := range files {
+		filename := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	timer := newParseTimer()
+	cfg := &packages.Config{
+		Dir:       dir,
+		Mode:      packages.LoadAllSyntax,
+		ParseFile: timer.ParseFile,
+	}
+	pkgs, err := packages.Load(cfg, "example.com/timed/b")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewCache()
+	c.addTimed(pkgs, timer)
+	checkCached(t, c, "example.com/timed/a", "example.com/timed/b")
+
+	// The import is timed too, the files are in the order of the package files.
+	for path, want := range map[string][]string{
+		"example.com/timed/a": {"a.go"},
+		"example.com/timed/b": {"b.go", "c.go"},
+	} {
+		timings := c.Timings(path)
+		var got []string
+		for _, f := range timings.Files {
+			got = append(got, filepath.Base(f.Filename))
+			if f.Start.IsZero() || f.End.Before(f.Start) {
+				t.Errorf("%s timing = %+v, want an end after its start", f.Filename, f)
+			}
+		}
+		if strings.Join(got, " ") != strings.Join(want, " ") {
+			t.Errorf("%s file timings = %v, want %v", path, got, want)
+		}
+		if timings.Check() != 0 {
+			t.Errorf("%s check timing = %v, want zero since go/packages type-checked it", path, timings.Check())
+		}
+		if _, ok := timings.Slowest(); !ok {
+			t.Errorf("%s has no slowest file", path)
+		}
+	}
+
+	// Packages added without a timer have no timings.
+	c = NewCache()
+	c.AddAll(pkgs)
+	if timings := c.Timings("example.com/timed/b"); len(timings.Files) != 0 {
+		t.Errorf("timings without a timer = %+v, want zero", timings)
+	}
+}
+
+func TestPaths(t *testing.T) {
+	c := NewCache()
+	if paths := c.Paths(); len(paths) != 0 {
+		t.Errorf("Paths() of an empty cache = %v, want none", paths)
+	}
+
+	z := testLoad(t, "z", "package z\n")
+	m := testLoad(t, "m", "package m\n\nimport _ \"z\"\n", z)
+	a := testLoad(t, "a", "package a\n")
+	c.AddAll([]*packages.Package{m, a})
+
+	if got, want := fmt.Sprint(c.Paths()), "[a m z]"; got != want {
+		t.Errorf("Paths() = %s, want %s", got, want)
+	}
+	c.Delete("z")
+	if got, want := fmt.Sprint(c.Paths()), "[a]"; got != want {
+		t.Errorf("Paths() after deleting z = %s, want %s", got, want)
+	}
+}
@@ -44,6 +44,21 @@ type astFile struct {
 	err       error // parse errors
 	ph        source.ParseGoHandle
 	isTrimmed bool
+	// tok is the token file of file, and [base, end) its range of positions, tok is nil when unknown
+	tok       *token.File
+	base, end token.Pos
+}
+
+// setToken record the token file of the parsed file, so that position lookups do not search the file set
+func (f *astFile) setToken(fset *token.FileSet) {
+	if f.file == nil || fset == nil {
+		return
+	}
+	f.tok = fset.File(f.file.Pos())
+	if f.tok != nil {
+		f.base = token.Pos(f.tok.Base())
+		f.end = f.base + token.Pos(f.tok.Size())
+	}
 }
 
 func (f *goFile) GetToken(ctx context.Context) *token.File {
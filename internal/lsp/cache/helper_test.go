@@ -0,0 +1,77 @@
+package cache
+
+import (
+	"go/ast"
+	goimporter "go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"testing"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// testFset and testStd are shared by all packages built by testLoad, so
+// that positions and standard library types stay comparable across them.
+var (
+	testFset = token.NewFileSet()
+	testStd  = goimporter.Default()
+)
+
+// testLoad parses and type-checks src as the single file of package path.
+// The package may import any of deps as well as the standard library.
+func testLoad(t *testing.T, path, src string, deps ...*packages.Package) *packages.Package {
+	t.Helper()
+	return testLoadFile(t, "/src/"+path+"/"+path+".go", path, src, deps...)
+}
+
+// testLoadFile is testLoad for a file named filename, such as a file on disk.
+func testLoadFile(t *testing.T, filename, path, src string, deps ...*packages.Package) *packages.Package {
+	t.Helper()
+
+	f, err := parser.ParseFile(testFset, filename, src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testCheck(path, []*ast.File{f}, deps...)
+}
+
+// testCheck type-checks the files as package path.
+func testCheck(path string, files []*ast.File, deps ...*packages.Package) *packages.Package {
+	p := &packages.Package{
+		ID:      path,
+		Name:    files[0].Name.Name,
+		PkgPath: path,
+		Fset:    testFset,
+		Syntax:  files,
+		Imports: make(map[string]*packages.Package),
+		TypesInfo: &types.Info{
+			Types:      make(map[ast.Expr]types.TypeAndValue),
+			Defs:       make(map[*ast.Ident]types.Object),
+			Uses:       make(map[*ast.Ident]types.Object),
+			Implicits:  make(map[ast.Node]types.Object),
+			Selections: make(map[*ast.SelectorExpr]*types.Selection),
+			Scopes:     make(map[ast.Node]*types.Scope),
+		},
+	}
+	for _, dep := range deps {
+		p.Imports[dep.PkgPath] = dep
+	}
+	cfg := &types.Config{
+		Importer: importerFunc(func(path string) (*types.Package, error) {
+			if dep, ok := p.Imports[path]; ok {
+				return dep.Types, nil
+			}
+			return testStd.Import(path)
+		}),
+		Error: func(err error) {
+			p.Errors = append(p.Errors, packages.Error{Msg: err.Error(), Kind: packages.TypeError})
+		},
+	}
+	p.Types, _ = cfg.Check(path, testFset, p.Syntax, p.TypesInfo)
+	return p
+}
+
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) { return f(path) }
@@ -17,10 +17,12 @@ func newModule(w *Workspace, rootPath string) *module {
 }
 
 func (m *module) buildCache() error {
+	timer := newParseTimer()
 	cfg := packages.Config{
-		Dir:  m.rootPath,
-		Fset: m.w.session.cache.FileSet(),
-		Mode: packages.LoadAllSyntax,
+		Dir:       m.rootPath,
+		Fset:      m.w.session.cache.FileSet(),
+		Mode:      packages.LoadAllSyntax,
+		ParseFile: timer.ParseFile,
 	}
 
 	pkgList, err := packages.Load(&cfg, cfg.Dir+"/...")
@@ -28,6 +30,6 @@ func (m *module) buildCache() error {
 		return err
 	}
 
-	m.w.setCache(pkgList)
+	m.w.setCache(pkgList, timer)
 	return nil
 }
@@ -38,6 +38,8 @@ type pkg struct {
 
 	diagMu      sync.Mutex
 	diagnostics []source.Diagnostic
+
+	timings PackageTimings
 }
 
 // packageID is a type that abstracts a package ID.
@@ -165,6 +167,17 @@ func (pkg *pkg) GetSyntax() []*ast.File {
 	return syntax
 }
 
+// GetSyntaxRanges return the syntax files of the package with their range of positions, which is zero when unknown
+func (pkg *pkg) GetSyntaxRanges() []source.SyntaxRange {
+	var ranges []source.SyntaxRange
+	for _, f := range pkg.files {
+		if f.file != nil {
+			ranges = append(ranges, source.SyntaxRange{File: f.file, Start: f.base, End: f.end})
+		}
+	}
+	return ranges
+}
+
 func (pkg *pkg) GetErrors() []packages.Error {
 	return pkg.errors
 }
@@ -81,6 +81,7 @@ func (s *session) NewView(name string, folder span.URI) source.View {
 		folder:        folder,
 		filesByURI:    make(map[span.URI]viewFile),
 		filesByBase:   make(map[string][]viewFile),
+		filesByReal:   make(map[string]viewFile),
 		mcache: &metadataCache{
 			packages: make(map[packageID]*metadata),
 			ids:      make(map[packagePath]packageID),
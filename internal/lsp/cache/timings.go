@@ -0,0 +1,88 @@
+package cache
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"sync"
+	"time"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// FileTiming is the time spent parsing a file
+type FileTiming struct {
+	Filename   string
+	Start, End time.Time
+}
+
+// Parse return the time spent parsing the file
+func (t FileTiming) Parse() time.Duration {
+	return t.End.Sub(t.Start)
+}
+
+// PackageTimings is the time spent parsing and type-checking a package,
+// the files are parsed before the package is type-checked.
+// Packages loaded by go/packages only record the time spent parsing their files with a parseTimer,
+// go/packages type-check them itself, until ReanalyzePackage check them again.
+type PackageTimings struct {
+	// Files are in the order of the package files
+	Files                []FileTiming
+	CheckStart, CheckEnd time.Time
+}
+
+// Check return the time spent type-checking the package
+func (t PackageTimings) Check() time.Duration {
+	return t.CheckEnd.Sub(t.CheckStart)
+}
+
+// Slowest return the file which took the longest to parse, such as a large generated file
+func (t PackageTimings) Slowest() (FileTiming, bool) {
+	var slowest FileTiming
+	for _, f := range t.Files {
+		if f.Parse() > slowest.Parse() {
+			slowest = f
+		}
+	}
+	return slowest, len(t.Files) > 0
+}
+
+// parseTimer record the time spent parsing the files of a go/packages load, as the ParseFile of its config
+type parseTimer struct {
+	mu    sync.Mutex
+	files map[*ast.File]FileTiming
+}
+
+func newParseTimer() *parseTimer {
+	return &parseTimer{files: map[*ast.File]FileTiming{}}
+}
+
+// ParseFile parse the file as go/packages does by default, recording the time spent
+func (t *parseTimer) ParseFile(fset *token.FileSet, filename string, src []byte) (*ast.File, error) {
+	start := time.Now()
+	f, err := parser.ParseFile(fset, filename, src, parser.AllErrors|parser.ParseComments)
+	end := time.Now()
+	if f != nil {
+		t.mu.Lock()
+		t.files[f] = FileTiming{Filename: filename, Start: start, End: end}
+		t.mu.Unlock()
+	}
+	return f, err
+}
+
+// timings return the time spent parsing the files of the package, zero when t is nil
+func (t *parseTimer) timings(p *packages.Package) PackageTimings {
+	var timings PackageTimings
+	if t == nil {
+		return timings
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, f := range p.Syntax {
+		if ft, ok := t.files[f]; ok {
+			timings.Files = append(timings.Files, ft)
+		}
+	}
+	return timings
+}
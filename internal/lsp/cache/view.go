@@ -55,6 +55,9 @@ type view struct {
 	// to multiple uris, and the same basename may map to multiple files
 	filesByURI  map[span.URI]viewFile
 	filesByBase map[string][]viewFile
+	// filesByReal keeps track of files by filename with symbolic links
+	// resolved, so that a file is found through any link to it
+	filesByReal map[string]viewFile
 
 	// mcache caches metadata for the packages of the opened files in a view.
 	mcache *metadataCache
@@ -372,8 +375,15 @@ func (v *view) findFile(uri span.URI) (viewFile, error) {
 		return f, nil
 	}
 	// no exact match stored, time to do some real work
-	// check for any files with the same basename
+	// check for a file the uri links to
 	fname := uri.Filename()
+	if real, err := filepath.EvalSymlinks(fname); err == nil {
+		if f := v.filesByReal[real]; f != nil {
+			v.mapFile(uri, f)
+			return f, nil
+		}
+	}
+	// check for any files with the same basename
 	basename := basename(fname)
 	if candidates := v.filesByBase[basename]; candidates != nil {
 		pathStat, err := os.Stat(fname)
@@ -406,6 +416,9 @@ func (v *view) mapFile(uri span.URI, f viewFile) {
 	if f.addURI(uri) == 1 {
 		basename := basename(f.filename())
 		v.filesByBase[basename] = append(v.filesByBase[basename], f)
+		if real, err := filepath.EvalSymlinks(f.filename()); err == nil {
+			v.filesByReal[real] = f
+		}
 	}
 }
 
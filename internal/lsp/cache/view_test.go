@@ -0,0 +1,56 @@
+package cache
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/internal/span"
+)
+
+func TestFindFileThroughSymlinks(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "view")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	real := filepath.Join(tmp, "real")
+	if err := os.Mkdir(real, 0755); err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(real, "a.go")
+	if err := ioutil.WriteFile(filename, []byte("package a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// A link to the directory, and a link to the file under another name.
+	dirLink, fileLink := filepath.Join(tmp, "link"), filepath.Join(tmp, "b.go")
+	if err := os.Symlink(real, dirLink); err != nil {
+		t.Skipf("cannot create symlink: %v", err)
+	}
+	if err := os.Symlink(filename, fileLink); err != nil {
+		t.Fatal(err)
+	}
+
+	v := &view{
+		filesByURI:  make(map[span.URI]viewFile),
+		filesByBase: make(map[string][]viewFile),
+		filesByReal: make(map[string]viewFile),
+	}
+	f := &goFile{fileBase: fileBase{view: v, fname: filename}}
+	v.mapFile(span.FileURI(filename), f)
+
+	for _, name := range []string{filename, filepath.Join(dirLink, "a.go"), fileLink} {
+		got, err := v.findFile(span.FileURI(name))
+		if err != nil || got != f {
+			t.Errorf("findFile(%s) = %v, %v, want the file of %s", name, got, err, filename)
+		}
+	}
+	if got := len(f.uris); got != 3 {
+		t.Errorf("file mapped to %d uris, want 3", got)
+	}
+	if got, _ := v.findFile(span.FileURI(filepath.Join(tmp, "c.go"))); got != nil {
+		t.Errorf("findFile of a missing file = %v, want nil", got)
+	}
+}
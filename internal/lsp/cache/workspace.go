@@ -193,10 +193,12 @@ func (w *Workspace) Search(walkFunc source.WalkFunc) {
 	w.cache.Walk(walkFunc)
 }
 
-func (w *Workspace) setCache(pkgs []*packages.Package) {
-	for _, pkg := range pkgs {
-		w.cache.Add(pkg)
+func (w *Workspace) setCache(pkgs []*packages.Package, timer *parseTimer) {
+	if c, ok := w.cache.(*globalCache); ok {
+		c.addTimed(pkgs, timer)
+		return
 	}
+	w.cache.AddAll(pkgs)
 }
 
 func (w *Workspace) Put(pkg *pkg) {
@@ -6,6 +6,7 @@ import (
 	"go/token"
 	"go/types"
 	"reflect"
+	"strings"
 
 	"golang.org/x/tools/go/ast/astutil"
 )
@@ -40,9 +41,9 @@ func findInterestingNode(pkg Package, path []ast.Node) ([]ast.Node, action) {
 	// TODO(adonovan): audit for ParenExpr safety, esp. since we
 	// traverse up and down.
 
-	// TODO(adonovan): if the users selects the "." in
-	// "fmt.Fprintf()", they'll get an ambiguous selection error;
-	// we won't even reach here.  Can we do better?
+	// Selecting the "." in "fmt.Fprintf()", or the space around it,
+	// yields a path starting at the SelectorExpr, which descends to
+	// its Sel below.
 
 	// TODO(adonovan): describing a field within 'type T struct {...}'
 	// describes the (anonymous) struct type and concludes "no methods".
@@ -56,6 +57,9 @@ func findInterestingNode(pkg Package, path []ast.Node) ([]ast.Node, action) {
 				path = append([]ast.Node{n.Specs[0]}, path...)
 				continue
 			}
+			// A position within one of several specs yields a path through
+			// that spec, so the GenDecl itself is only selected outside
+			// them: on the keyword, the parentheses or blank space.
 			return path, actionUnknown // uninteresting
 
 		case *ast.FuncDecl:
@@ -79,6 +83,14 @@ func findInterestingNode(pkg Package, path []ast.Node) ([]ast.Node, action) {
 			path = append([]ast.Node{n.Name}, path...)
 			continue
 
+		case *ast.CaseClause:
+			if len(n.List) == 1 {
+				// Descend to sole case expression, e.g. a constant.
+				path = append([]ast.Node{n.List[0]}, path...)
+				continue
+			}
+			return path, actionStmt
+
 		case ast.Stmt:
 			return path, actionStmt
 
@@ -107,8 +119,13 @@ func findInterestingNode(pkg Package, path []ast.Node) ([]ast.Node, action) {
 				path = append([]ast.Node{n.Names[0]}, path...)
 				continue
 			}
-			// Zero names (e.g. anon field in struct)
-			// or multiple field or param names:
+			if len(n.Names) == 0 {
+				// Descend to the type of an embedded field, e.g.
+				// io.Reader or *T in 'struct { io.Reader; *T }'.
+				path = append([]ast.Node{n.Type}, path...)
+				continue
+			}
+			// Multiple field or param names:
 			// continue to enclosing field list.
 
 		case *ast.FieldList:
@@ -146,19 +163,23 @@ func findInterestingNode(pkg Package, path []ast.Node) ([]ast.Node, action) {
 				return path, actionType
 
 			case *types.Var:
-				// For x in 'struct {x T}', return struct type, for now.
-				if _, ok := path[1].(*ast.Field); ok {
-					_ = path[2].(*ast.FieldList) // assertion
-					if _, ok := path[3].(*ast.StructType); ok {
-						return path[3:], actionType
-					}
+				// For T in 'struct {T}', the field name denotes the type.
+				if v := pkg.GetTypesInfo().Defs[n]; v != nil && v.(*types.Var).Embedded() {
+					return path, actionType
 				}
+				// For x in 'struct {x T}', x denotes the field itself, even
+				// when the struct type is anonymous.
 				return path, actionExpr
 
 			case *types.Func:
 				return path, actionExpr
 
 			case *types.Builtin:
+				// For new(T) and make(T, ...), descend to the type argument.
+				if call, ok := path[1].(*ast.CallExpr); ok && call.Fun == n && len(call.Args) > 0 && (n.Name == "new" || n.Name == "make") {
+					path = append([]ast.Node{call.Args[0]}, path[1:]...)
+					continue
+				}
 				// For reference to built-in function, return enclosing call.
 				path = path[1:] // ascend to enclosing function call
 				continue
@@ -208,7 +229,48 @@ func findInterestingNode(pkg Package, path []ast.Node) ([]ast.Node, action) {
 				return path, actionUnknown
 			}
 
+		case *ast.BinaryExpr:
+			// A union of type terms in a constraint, e.g. 'int | string'.
+			if n.Op == token.OR && isTypeExpr(pkg.GetTypesInfo(), n) {
+				return path, actionType
+			}
+			return path, actionExpr
+
+		case *ast.UnaryExpr:
+			// A tilde type term in a constraint, e.g. '~int'.
+			if n.Op == token.TILDE {
+				path = append([]ast.Node{n.X}, path...)
+				continue
+			}
+			return path, actionExpr
+
+		case *ast.IndexExpr, *ast.IndexListExpr:
+			// The syntax x[i] is shared by index expressions and
+			// instantiations of generic types and functions.
+			if pkg.GetTypesInfo().Types[n.(ast.Expr)].IsType() {
+				return path, actionType
+			}
+			var x ast.Expr
+			if index, ok := n.(*ast.IndexExpr); ok {
+				x = index.X
+			} else {
+				x = n.(*ast.IndexListExpr).X
+			}
+			if _, ok := pkg.GetTypesInfo().TypeOf(x).(*types.Signature); ok {
+				// Only a generic function can be indexed; descend to it.
+				path = append([]ast.Node{x}, path...)
+				continue
+			}
+			return path, actionExpr
+
 		case *ast.StarExpr:
+			// For *T in a method receiver, descend to the type name T.
+			if field, ok := path[1].(*ast.Field); ok && field.Type == n && isReceiverField(path[1:]) {
+				if id := receiverTypeIdent(n); id != nil {
+					path = append([]ast.Node{id}, path...)
+					continue
+				}
+			}
 			if pkg.GetTypesInfo().Types[n].IsType() {
 				return path, actionType
 			}
@@ -227,6 +289,52 @@ func findInterestingNode(pkg Package, path []ast.Node) ([]ast.Node, action) {
 	return nil, actionUnknown // unreachable
 }
 
+// isReceiverField reports whether path[0] is the receiver of a method
+// declaration.
+func isReceiverField(path []ast.Node) bool {
+	if len(path) < 3 {
+		return false
+	}
+	decl, ok := path[2].(*ast.FuncDecl)
+	return ok && decl.Recv != nil && decl.Recv == path[1]
+}
+
+// receiverTypeIdent returns the type name of the receiver type expression
+// x, such as T in *T or T[K], or nil if there is none.
+func receiverTypeIdent(x ast.Expr) *ast.Ident {
+	for {
+		switch t := x.(type) {
+		case *ast.Ident:
+			return t
+		case *ast.ParenExpr:
+			x = t.X
+		case *ast.StarExpr:
+			x = t.X
+		case *ast.IndexExpr:
+			x = t.X
+		case *ast.IndexListExpr:
+			x = t.X
+		default:
+			return nil
+		}
+	}
+}
+
+// isTypeExpr reports whether x denotes a type. Union terms of a constraint
+// are only recorded individually, so a union is a type if its terms are.
+func isTypeExpr(info *types.Info, x ast.Expr) bool {
+	if tv, ok := info.Types[x]; ok {
+		return tv.IsType()
+	}
+	if b, ok := x.(*ast.BinaryExpr); ok && b.Op == token.OR {
+		return isTypeExpr(info, b.X) && isTypeExpr(info, b.Y)
+	}
+	if u, ok := x.(*ast.UnaryExpr); ok && u.Op == token.TILDE {
+		return isTypeExpr(info, u.X)
+	}
+	return false
+}
+
 func isAlias(obj *types.TypeName) bool {
 	return obj.IsAlias()
 }
@@ -255,10 +363,9 @@ func newInvalidNodeError(fset *token.FileSet, node ast.Node) *InvalidNodeError {
 func getObjectPathNode(pkg Package, fset *token.FileSet, o types.Object) (nodes []ast.Node, ident *ast.Ident, err error) {
 	nodes, _ = getPathNodes(pkg, fset, o.Pos(), o.Pos())
 	if len(nodes) == 0 {
-		ip := pkg.GetImport(o.Pkg().Path())
+		ip := findImport(pkg, o.Pkg().Path())
 		if ip == nil {
-			return nil, nil,
-				fmt.Errorf("import package %s of package %s does not exist", o.Pkg().Path(), pkg.GetTypes().Path())
+			return nil, nil, &PackageNotFoundError{Path: o.Pkg().Path()}
 		}
 
 		nodes, err = getPathNodes(ip, fset, o.Pos(), o.Pos())
@@ -271,8 +378,51 @@ func getObjectPathNode(pkg Package, fset *token.FileSet, o types.Object) (nodes
 	return
 }
 
+// findImport returns the package pkgPath imported by pkg, directly or
+// through its imports, since an object such as a field may be selected
+// through a package that is not imported by pkg itself.
+func findImport(pkg Package, pkgPath string) Package {
+	seen := map[Package]bool{pkg: true}
+	queue := []Package{pkg}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		ip := p.GetImport(pkgPath)
+		if ip == nil {
+			// Imports may be recorded by the path written in the import
+			// declaration, which omits the vendor directory.
+			ip = p.GetImport(unvendoredPath(pkgPath))
+		}
+		if ip != nil {
+			return ip
+		}
+		if p.GetTypes() == nil {
+			continue
+		}
+		for _, imp := range p.GetTypes().Imports() {
+			if ip := p.GetImport(imp.Path()); ip != nil && !seen[ip] {
+				seen[ip] = true
+				queue = append(queue, ip)
+			}
+		}
+	}
+	return nil
+}
+
+// unvendoredPath returns the import path that refers to the package path
+// pkgPath from within the tree holding its vendor directory.
+func unvendoredPath(pkgPath string) string {
+	if i := strings.LastIndex(pkgPath, "/vendor/"); i >= 0 {
+		return pkgPath[i+len("/vendor/"):]
+	}
+	return strings.TrimPrefix(pkgPath, "vendor/")
+}
+
 func getPathNodes(pkg Package, fset *token.FileSet, start, end token.Pos) ([]ast.Node, error) {
-	nodes, _ := astPathEnclosingInterval(pkg, fset, start, end)
+	nodes, _, err := astPathEnclosingInterval(pkg, fset, start, end)
+	if err != nil {
+		return nil, err
+	}
 	if len(nodes) == 0 {
 		s := fset.Position(start)
 		return nodes, fmt.Errorf("no node found at %s offset %d", s, s.Offset)
@@ -298,40 +448,132 @@ func fetchIdentFromPathNodes(fset *token.FileSet, nodes []ast.Node) (*ast.Ident,
 //
 // The zero value is returned if not found.
 //
-func astPathEnclosingInterval(pkg Package, fset *token.FileSet, start, end token.Pos) (path []ast.Node, exact bool) {
-	path, exact = doEnclosingInterval(pkg, fset, start, end)
+func astPathEnclosingInterval(pkg Package, fset *token.FileSet, start, end token.Pos) (path []ast.Node, exact bool, err error) {
+	path, exact, err = doEnclosingInterval(pkg, fset, start, end)
 	return
 }
 
-func doEnclosingInterval(pkg Package, fset *token.FileSet, start, end token.Pos) ([]ast.Node, bool) {
-	if pkg == nil || pkg.GetSyntax() == nil {
-		return nil, false
-	}
+// SyntaxRange is a syntax file of a package along with the range of
+// positions [Start, End) of its token file, or zero if unknown.
+type SyntaxRange struct {
+	File       *ast.File
+	Start, End token.Pos
+}
+
+// syntaxRanger is implemented by packages that record the range of
+// positions of their files, which spares searching the file set for the
+// file containing a position.
+type syntaxRanger interface {
+	GetSyntaxRanges() []SyntaxRange
+}
 
+// syntaxRanges returns the syntax files of pkg with their ranges, if known.
+func syntaxRanges(pkg Package) []SyntaxRange {
+	if r, ok := pkg.(syntaxRanger); ok {
+		return r.GetSyntaxRanges()
+	}
+	var ranges []SyntaxRange
 	for _, f := range pkg.GetSyntax() {
+		ranges = append(ranges, SyntaxRange{File: f})
+	}
+	return ranges
+}
+
+func doEnclosingInterval(pkg Package, fset *token.FileSet, start, end token.Pos) ([]ast.Node, bool, error) {
+	if pkg == nil {
+		return nil, false, nil
+	}
+
+	for _, r := range syntaxRanges(pkg) {
+		f := r.File
 		if f.Pos() == token.NoPos {
 			// This can happen if the parser saw
 			// too many errors and bailed out.
 			// (Use parser.AllErrors to prevent that.)
 			continue
 		}
-		if !tokenFileContainsPos(fset.File(f.Pos()), start) {
+		if r.End.IsValid() {
+			if start < r.Start || start >= r.End {
+				continue
+			}
+		} else if tok := fset.File(f.Pos()); tok == nil {
+			return nil, false, fmt.Errorf("a file of package %s is not in the file set", pkg.PkgPath())
+		} else if !tokenFileContainsPos(tok, start) {
 			continue
 		}
-		if path, exact := astutil.PathEnclosingInterval(f, start, end); path != nil {
-			return path, exact
+		path, exact, err := pathEnclosingIntervalLimited(f, start, end)
+		if err != nil {
+			return nil, false, err
+		}
+		if path != nil {
+			return path, exact, nil
 		}
 	}
 
-	return nil, false
+	return nil, false, nil
 }
 
+// maxPathDepth is the maximum number of nested syntax nodes that may enclose
+// a queried position. Deeper paths are rejected with an error instead of
+// being walked, so that pathological input cannot exhaust the stack.
+const maxPathDepth = 10000
+
+// pathEnclosingIntervalLimited is like astutil.PathEnclosingInterval but
+// fails if the path would be deeper than maxPathDepth.
+func pathEnclosingIntervalLimited(f *ast.File, start, end token.Pos) ([]ast.Node, bool, error) {
+	if err := checkPathDepth(f, start, end, maxPathDepth); err != nil {
+		return nil, false, err
+	}
+	path, exact := astutil.PathEnclosingInterval(f, start, end)
+	return path, exact, nil
+}
+
+// checkPathDepth reports an error if more than limit nodes of f enclose the
+// interval [start, end). It never descends past the limit.
+func checkPathDepth(f *ast.File, start, end token.Pos, limit int) error {
+	depth, tooDeep := 0, false
+	ast.Inspect(f, func(n ast.Node) bool {
+		if n == nil {
+			depth--
+			return false
+		}
+		if tooDeep || n.End() < start || n.Pos() > end {
+			return false
+		}
+		if depth++; depth > limit {
+			tooDeep = true
+			depth--
+			return false
+		}
+		return true
+	})
+	if tooDeep {
+		return fmt.Errorf("syntax tree is nested deeper than %d levels", limit)
+	}
+	return nil
+}
+
+// findObject returns the object defined in pkg that is o, or that is
+// declared by the same identifier as o when pkg was type-checked separately.
+// Matching on names alone would confuse shadowed declarations.
 func findObject(pkg Package, o types.Object) types.Object {
+	var found types.Object
 	for _, def := range pkg.GetTypesInfo().Defs {
-		if def == nil {
-			continue
+		if def == o {
+			return def
 		}
-		if def.Name() == o.Name() {
+		if def != nil && found == nil && def.Name() == o.Name() && def.Pos() == o.Pos() {
+			found = def
+		}
+	}
+
+	return found
+}
+
+// findObjectAt returns the object defined in pkg by the identifier at pos.
+func findObjectAt(pkg Package, pos token.Pos) types.Object {
+	for id, def := range pkg.GetTypesInfo().Defs {
+		if def != nil && id.Pos() <= pos && pos <= id.End() {
 			return def
 		}
 	}
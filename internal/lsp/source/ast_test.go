@@ -0,0 +1,144 @@
+package source
+
+import (
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+)
+
+func TestPathDepthLimit(t *testing.T) {
+	const depth = 20000
+	src := "package deep\n\nvar x = " + strings.Repeat("(", depth) + "1" + strings.Repeat(")", depth) + "\n"
+	fset, pkgs := loadTestPackages(t, testSource{path: "deep", files: map[string]string{"deep.go": src}})
+	pos := testPos(t, fset, pkgs[0], "deep.go", "1)")
+
+	if _, err := Definition(pkgs[0], fset, pos); err == nil || !strings.Contains(err.Error(), "nested deeper") {
+		t.Errorf("got error %v, want depth limit error", err)
+	}
+
+	if err := checkPathDepth(pkgs[0].syntax[0], pos, pos, 2*depth); err != nil {
+		t.Errorf("unexpected error with raised limit: %v", err)
+	}
+}
+
+func TestFindObject(t *testing.T) {
+	fset, pkgs := loadTestPackages(t, testSource{path: "shadow", files: map[string]string{"shadow.go": `package shadow
+
+var err error
+
+type T struct{}
+
+func (t T) Method(err error) error {
+	if err != nil {
+		err := t.check()
+		return err
+	}
+	return nil
+}
+
+func (T) check() error { return nil }
+`}})
+	p := pkgs[0]
+
+	// Type-checking the same syntax again yields distinct objects at the
+	// same positions.
+	recheck := &testPackage{typesInfo: &types.Info{Defs: make(map[*ast.Ident]types.Object)}}
+	cfg := &types.Config{Importer: importer.Default()}
+	if _, err := cfg.Check("shadow", fset, p.syntax, recheck.typesInfo); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, marker := range []string{"var ^err", "Method(^err", "\t\t^err :="} {
+		pos := testPos(t, fset, p, "shadow.go", marker)
+		obj := findObjectAt(p, pos)
+		if obj == nil || obj.Name() != "err" || obj.Pos() != pos {
+			t.Fatalf("%s: findObjectAt = %v, want err declared there", marker, obj)
+		}
+		if got := findObject(p, obj); got != obj {
+			t.Errorf("%s: findObject = %v, want itself", marker, got)
+		}
+		if got := findObject(recheck, obj); got == nil || got == obj || got.Pos() != pos {
+			t.Errorf("%s: findObject in the rechecked package = %v, want its err declared at the same position", marker, got)
+		}
+	}
+}
+
+// rangedPackage is a testPackage recording the ranges of its files.
+type rangedPackage struct {
+	*testPackage
+	ranges []SyntaxRange
+}
+
+func (p *rangedPackage) GetSyntaxRanges() []SyntaxRange { return p.ranges }
+
+// newRangedPackage returns p with the ranges of its files in fset.
+func newRangedPackage(fset *token.FileSet, p *testPackage) *rangedPackage {
+	rp := &rangedPackage{testPackage: p}
+	for _, f := range p.syntax {
+		tok := fset.File(f.Pos())
+		rp.ranges = append(rp.ranges, SyntaxRange{File: f, Start: token.Pos(tok.Base()), End: token.Pos(tok.Base() + tok.Size())})
+	}
+	return rp
+}
+
+func TestEnclosingIntervalRanges(t *testing.T) {
+	fset, pkgs := loadTestPackages(t, testSource{path: "ranges", files: map[string]string{
+		"a.go": "package ranges\n\nvar A = 1\n",
+		"b.go": "package ranges\n\nvar B = A\n",
+	}})
+	p := pkgs[0]
+	ranged := newRangedPackage(fset, p)
+	// A file of unknown range is searched in the file set.
+	ranged.ranges[0].Start, ranged.ranges[0].End = token.NoPos, token.NoPos
+
+	for _, test := range []struct{ file, marker string }{
+		{"a.go", "var ^A"},
+		{"b.go", "var ^B"},
+		{"b.go", "= ^A"},
+	} {
+		pos := testPos(t, fset, p, test.file, test.marker)
+		want, _, _ := doEnclosingInterval(p, fset, pos, pos)
+		got, _, _ := doEnclosingInterval(ranged, fset, pos, pos)
+		if len(got) == 0 || len(got) != len(want) || got[0] != want[0] {
+			t.Errorf("%s: got path %v, want %v", test.marker, got, want)
+		}
+	}
+}
+
+// benchmarkEnclosingInterval looks up a position in each of the 200 files
+// of a package.
+func benchmarkEnclosingInterval(b *testing.B, ranged bool) {
+	fset := token.NewFileSet()
+	p := &testPackage{}
+	var positions []token.Pos
+	for i := 0; i < 200; i++ {
+		src := fmt.Sprintf("package big\n\nfunc f%d() int {\n\treturn %d\n}\n", i, i)
+		f, err := parser.ParseFile(fset, fmt.Sprintf("/src/big/f%d.go", i), src, 0)
+		if err != nil {
+			b.Fatal(err)
+		}
+		p.syntax = append(p.syntax, f)
+		positions = append(positions, f.Decls[0].(*ast.FuncDecl).Body.List[0].Pos())
+	}
+	var pkg Package = p
+	if ranged {
+		pkg = newRangedPackage(fset, p)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, pos := range positions {
+			if path, _, _ := doEnclosingInterval(pkg, fset, pos, pos); path == nil {
+				b.Fatal("no path found")
+			}
+		}
+	}
+}
+
+func BenchmarkEnclosingIntervalFileSet(b *testing.B) { benchmarkEnclosingInterval(b, false) }
+func BenchmarkEnclosingIntervalRanges(b *testing.B)  { benchmarkEnclosingInterval(b, true) }
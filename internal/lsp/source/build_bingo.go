@@ -0,0 +1,146 @@
+package source
+
+import (
+	"fmt"
+	"go/build/constraint"
+	"go/token"
+	"strings"
+
+	"golang.org/x/tools/internal/span"
+)
+
+// BuildOp is the operator of a BuildExpr node.
+type BuildOp int
+
+const (
+	// BuildTag is a leaf node testing a single build tag.
+	BuildTag BuildOp = iota
+	// BuildNot negates X.
+	BuildNot
+	// BuildAnd requires both X and Y.
+	BuildAnd
+	// BuildOr requires either X or Y.
+	BuildOr
+)
+
+// TagKind classifies the build tag of a BuildTag node.
+type TagKind int
+
+const (
+	// TagOther is a tag that is neither a GOOS nor a GOARCH value, such as
+	// a custom tag, cgo, unix or a release tag like go1.18.
+	TagOther TagKind = iota
+	// TagGOOS is an operating system, such as linux.
+	TagGOOS
+	// TagGOARCH is an architecture, such as amd64.
+	TagGOARCH
+)
+
+// BuildExpr is a node of the boolean expression of a file's build
+// constraint.
+type BuildExpr struct {
+	Op BuildOp
+	// Tag and Kind are set for a BuildTag node.
+	Tag  string
+	Kind TagKind
+	// X is the operand of a BuildNot node and the left operand of a
+	// BuildAnd or BuildOr node, whose right operand is Y.
+	X, Y *BuildExpr
+}
+
+// String renders e in prefix form, such as
+// and(goos:linux, or(goarch:amd64, goarch:arm64)).
+func (e *BuildExpr) String() string {
+	switch e.Op {
+	case BuildNot:
+		return fmt.Sprintf("not(%s)", e.X)
+	case BuildAnd:
+		return fmt.Sprintf("and(%s, %s)", e.X, e.Y)
+	case BuildOr:
+		return fmt.Sprintf("or(%s, %s)", e.X, e.Y)
+	}
+	switch e.Kind {
+	case TagGOOS:
+		return "goos:" + e.Tag
+	case TagGOARCH:
+		return "goarch:" + e.Tag
+	}
+	return e.Tag
+}
+
+// BuildConstraints parses the build constraint of the file uri of pkg, from
+// its //go:build line or, failing that, its legacy // +build lines. It
+// returns nil if the file has no build constraint.
+func BuildConstraints(pkg Package, fset *token.FileSet, uri span.URI) (*BuildExpr, error) {
+	file, err := lintFile(pkg, fset, uri)
+	if err != nil {
+		return nil, err
+	}
+
+	var plusBuild []constraint.Expr
+	for _, group := range file.Comments {
+		if group.Pos() >= file.Package {
+			break
+		}
+		for _, c := range group.List {
+			if !constraint.IsGoBuild(c.Text) && !constraint.IsPlusBuild(c.Text) {
+				continue
+			}
+			x, err := constraint.Parse(c.Text)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", fset.Position(c.Pos()), err)
+			}
+			if constraint.IsGoBuild(c.Text) {
+				return toBuildExpr(x), nil
+			}
+			plusBuild = append(plusBuild, x)
+		}
+	}
+
+	// Multiple // +build lines must all be satisfied.
+	var expr *BuildExpr
+	for _, x := range plusBuild {
+		if expr == nil {
+			expr = toBuildExpr(x)
+		} else {
+			expr = &BuildExpr{Op: BuildAnd, X: expr, Y: toBuildExpr(x)}
+		}
+	}
+	return expr, nil
+}
+
+// toBuildExpr converts a parsed build constraint expression.
+func toBuildExpr(x constraint.Expr) *BuildExpr {
+	switch x := x.(type) {
+	case *constraint.NotExpr:
+		return &BuildExpr{Op: BuildNot, X: toBuildExpr(x.X)}
+	case *constraint.AndExpr:
+		return &BuildExpr{Op: BuildAnd, X: toBuildExpr(x.X), Y: toBuildExpr(x.Y)}
+	case *constraint.OrExpr:
+		return &BuildExpr{Op: BuildOr, X: toBuildExpr(x.X), Y: toBuildExpr(x.Y)}
+	case *constraint.TagExpr:
+		kind := TagOther
+		if knownOS[x.Tag] {
+			kind = TagGOOS
+		} else if knownArch[x.Tag] {
+			kind = TagGOARCH
+		}
+		return &BuildExpr{Op: BuildTag, Tag: x.Tag, Kind: kind}
+	}
+	return nil
+}
+
+// knownOS and knownArch are the GOOS and GOARCH values known to the go
+// command, which does not export them.
+var (
+	knownOS   = stringSet("aix android darwin dragonfly freebsd hurd illumos ios js linux nacl netbsd openbsd plan9 solaris wasip1 windows zos")
+	knownArch = stringSet("386 amd64 amd64p32 arm armbe arm64 arm64be loong64 mips mipsle mips64 mips64le mips64p32 mips64p32le ppc ppc64 ppc64le riscv riscv64 s390 s390x sparc sparc64 wasm")
+)
+
+func stringSet(list string) map[string]bool {
+	set := make(map[string]bool)
+	for _, s := range strings.Fields(list) {
+		set[s] = true
+	}
+	return set
+}
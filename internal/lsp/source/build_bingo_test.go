@@ -0,0 +1,55 @@
+package source
+
+import (
+	"testing"
+
+	"golang.org/x/tools/internal/span"
+)
+
+func TestBuildConstraints(t *testing.T) {
+	fset, pkgs := loadTestPackages(t, testSource{path: "plat", files: map[string]string{
+		"gobuild.go": `// Copyright notice.
+
+//go:build linux && (amd64 || arm64)
+// +build linux
+// +build amd64 arm64
+
+package plat
+`,
+		"plusbuild.go": `// +build !windows,cgo
+// +build mytag
+
+package plat
+`,
+		"none.go": `// Package plat has no constraint here.
+package plat
+
+// +build ignore is not a constraint after the package clause.
+`,
+	}})
+	p := pkgs[0]
+
+	for file, want := range map[string]string{
+		"gobuild.go":   "and(goos:linux, or(goarch:amd64, goarch:arm64))",
+		"plusbuild.go": "and(and(not(goos:windows), cgo), mytag)",
+		"none.go":      "<nil>",
+	} {
+		expr, err := BuildConstraints(p, fset, span.FileURI("/src/plat/"+file))
+		if err != nil {
+			t.Errorf("%s: %v", file, err)
+			continue
+		}
+		got := "<nil>"
+		if expr != nil {
+			got = expr.String()
+		}
+		if got != want {
+			t.Errorf("%s: got %s, want %s", file, got, want)
+		}
+	}
+
+	expr, _ := BuildConstraints(p, fset, span.FileURI("/src/plat/gobuild.go"))
+	if linux := expr.X; linux.Op != BuildTag || linux.Tag != "linux" || linux.Kind != TagGOOS {
+		t.Errorf("got left operand %+v, want GOOS tag linux", linux)
+	}
+}
@@ -1,10 +1,57 @@
 package source
 
+import "fmt"
+
 // WalkFunc walk function
 type WalkFunc func(p Package) bool
 
 type ICache interface {
 	Walk(walkFunc WalkFunc)
+	// Delete remove the package and the packages importing it, directly or transitively,
+	// and return their paths so they can be reloaded
+	Delete(pkgPath string) []string
 }
 
+// PackageNotFoundError is returned when a package is neither imported nor cached,
+// so that callers can load it
+type PackageNotFoundError struct {
+	Path string
+}
 
+func (e *PackageNotFoundError) Error() string {
+	return fmt.Sprintf("package %s not found", e.Path)
+}
+
+// Is report whether target is a PackageNotFoundError for the same path, or for any path
+// if its path is empty, so that errors.Is(err, &PackageNotFoundError{}) match any package
+func (e *PackageNotFoundError) Is(target error) bool {
+	t, ok := target.(*PackageNotFoundError)
+	return ok && (t.Path == "" || t.Path == e.Path)
+}
+
+// lookupPackage find package by package import path in the cache
+func lookupPackage(c ICache, pkgPath string) Package {
+	var found Package
+	c.Walk(func(p Package) bool {
+		if p.GetTypes() != nil && p.GetTypes().Path() == pkgPath {
+			found = p
+			return true
+		}
+		return false
+	})
+	return found
+}
+
+// ResolveImport find the package pkgPath imported by pkg, falling back to the cache
+// when the import was not recorded on pkg
+func ResolveImport(c ICache, pkg Package, pkgPath string) Package {
+	if ip := pkg.GetImport(pkgPath); ip != nil {
+		return ip
+	}
+	// Imports may be recorded by the path written in the import
+	// declaration, which omits the vendor directory.
+	if ip := pkg.GetImport(unvendoredPath(pkgPath)); ip != nil {
+		return ip
+	}
+	return lookupPackage(c, pkgPath)
+}
@@ -0,0 +1,52 @@
+package source
+
+import (
+	"errors"
+	"fmt"
+	"go/token"
+	"go/types"
+	"testing"
+)
+
+func TestResolveImport(t *testing.T) {
+	_, pkgs := loadTestPackages(t,
+		testSource{path: "lib", files: map[string]string{"lib.go": "package lib\n\nconst V = 1\n"}},
+		testSource{path: "app", files: map[string]string{"app.go": "package app\n\nimport \"lib\"\n\nconst V = lib.V\n"}},
+	)
+	lib, app := pkgs[0], pkgs[1]
+
+	// The cached copy of lib stands for another load of the package.
+	cached := &testPackage{id: "lib", types: lib.types}
+	c := testCache{cached, app}
+
+	if got := ResolveImport(c, app, "lib"); got != lib {
+		t.Errorf("ResolveImport of a recorded import = %v, want the recorded package", got)
+	}
+
+	delete(app.imports, "lib")
+	if got := ResolveImport(c, app, "lib"); got != cached {
+		t.Errorf("ResolveImport of an unrecorded import = %v, want the cached package", got)
+	}
+	if got := ResolveImport(c, app, "missing"); got != nil {
+		t.Errorf("ResolveImport of a missing package = %v, want nil", got)
+	}
+}
+
+func TestPackageNotFoundError(t *testing.T) {
+	fset, pkgs := loadTestPackages(t, testSource{path: "app", files: map[string]string{"app.go": "package app\n"}})
+	ghost := types.NewTypeName(token.NoPos, types.NewPackage("ghost", "ghost"), "T", nil)
+
+	_, _, err := getObjectPathNode(pkgs[0], fset, ghost)
+	var notFound *PackageNotFoundError
+	if !errors.As(err, &notFound) || notFound.Path != "ghost" {
+		t.Fatalf("got error %v, want a PackageNotFoundError for ghost", err)
+	}
+
+	wrapped := fmt.Errorf("definition: %w", err)
+	if !errors.Is(wrapped, &PackageNotFoundError{}) || !errors.Is(wrapped, &PackageNotFoundError{Path: "ghost"}) {
+		t.Errorf("errors.Is does not match %v", wrapped)
+	}
+	if errors.Is(wrapped, &PackageNotFoundError{Path: "other"}) {
+		t.Errorf("errors.Is matches %v for another package", wrapped)
+	}
+}
@@ -0,0 +1,163 @@
+package source
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"sort"
+)
+
+// ClassifyResult is the classification of a position by findInterestingNode.
+type ClassifyResult struct {
+	// Path is the path to the interesting node, or nil if the position is
+	// not in a file of the package.
+	Path   []ast.Node
+	Action action
+}
+
+// BatchClassify classifies each of positions as findInterestingNode does
+// the path enclosing it, in a single sweep over each file instead of one
+// descent from the file root per position, which is much cheaper for the
+// thousands of positions of features such as semantic tokens. The results
+// are in the order of positions.
+func BatchClassify(pkg Package, fset *token.FileSet, positions []token.Pos) ([]ClassifyResult, error) {
+	// Sort the indexes of positions, so that each file is swept in order.
+	order := make([]int, len(positions))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool { return positions[order[i]] < positions[order[j]] })
+
+	paths := make([][]ast.Node, len(positions))
+	for _, r := range syntaxRanges(pkg) {
+		f := r.File
+		if f.Pos() == token.NoPos {
+			continue
+		}
+		start, end := r.Start, r.End
+		if !end.IsValid() {
+			tok := fset.File(f.Pos())
+			if tok == nil {
+				return nil, fmt.Errorf("a file of package %s is not in the file set", pkg.PkgPath())
+			}
+			start, end = token.Pos(tok.Base()), token.Pos(tok.Base()+tok.Size())
+		}
+		lo := sort.Search(len(order), func(i int) bool { return positions[order[i]] >= start })
+		hi := sort.Search(len(order), func(i int) bool { return positions[order[i]] >= end })
+		if lo == hi {
+			continue
+		}
+		s := &sweep{positions: positions, paths: paths}
+		if err := s.file(f, order[lo:hi]); err != nil {
+			return nil, err
+		}
+	}
+
+	results := make([]ClassifyResult, len(positions))
+	for i, path := range paths {
+		if path == nil {
+			results[i].Action = actionUnknown
+			continue
+		}
+		results[i].Path, results[i].Action = findInterestingNode(pkg, path)
+	}
+	return results, nil
+}
+
+// sweep computes the paths enclosing sorted positions of a file, as
+// astutil.PathEnclosingInterval does for each of them: the path descends
+// into the child node whose interval contains the position, and stops at
+// a node when the position is in none of its children, such as on a token
+// or in whitespace between them.
+type sweep struct {
+	positions []token.Pos
+	paths     [][]ast.Node
+	// stack is the path from the file down to the current node.
+	stack []ast.Node
+}
+
+// file sets the paths of the positions indexed by order, which are sorted.
+func (s *sweep) file(f *ast.File, order []int) error {
+	// Positions before the first or after the last declaration are in
+	// the file itself.
+	var inside []int
+	for _, i := range order {
+		if p := s.positions[i]; p >= f.Pos() && p < f.End() {
+			inside = append(inside, i)
+		} else {
+			s.paths[i] = []ast.Node{f}
+		}
+	}
+	return s.visit(f, inside)
+}
+
+// visit sets the paths of the positions indexed by order, which are sorted
+// and within the interval of n.
+func (s *sweep) visit(n ast.Node, order []int) error {
+	if len(s.stack) >= maxPathDepth {
+		return fmt.Errorf("syntax tree is nested deeper than %d levels", maxPathDepth)
+	}
+	s.stack = append(s.stack, n)
+	defer func() { s.stack = s.stack[:len(s.stack)-1] }()
+
+	children := childNodes(n)
+	for len(order) > 0 {
+		p := s.positions[order[0]]
+		// Skip the children before p.
+		for len(children) > 0 && children[0].End() <= p {
+			children = children[1:]
+		}
+		if len(children) == 0 || p < children[0].Pos() {
+			// p is not in a child.
+			path := make([]ast.Node, len(s.stack))
+			for i, n := range s.stack {
+				path[len(path)-1-i] = n
+			}
+			s.paths[order[0]] = path
+			order = order[1:]
+			continue
+		}
+		child := children[0]
+		k := sort.Search(len(order), func(i int) bool { return s.positions[order[i]] >= child.End() })
+		if err := s.visit(child, order[:k]); err != nil {
+			return err
+		}
+		order = order[k:]
+	}
+	return nil
+}
+
+// childNodes returns the children of n that astutil.PathEnclosingInterval
+// descends into, sorted by position.
+func childNodes(n ast.Node) []ast.Node {
+	var children []ast.Node
+	if decl, ok := n.(*ast.FuncDecl); ok {
+		// The children of the function type are inlined, as the receiver
+		// precedes the func keyword of the type. The doc comment is not a
+		// child.
+		if decl.Recv != nil {
+			children = append(children, decl.Recv)
+		}
+		children = append(children, decl.Name)
+		for _, list := range []*ast.FieldList{decl.Type.TypeParams, decl.Type.Params, decl.Type.Results} {
+			if list != nil {
+				children = append(children, list)
+			}
+		}
+		if decl.Body != nil {
+			children = append(children, decl.Body)
+		}
+	} else {
+		ast.Inspect(n, func(child ast.Node) bool {
+			if child == n {
+				return true
+			}
+			if child != nil {
+				children = append(children, child)
+			}
+			return false
+		})
+	}
+	sort.SliceStable(children, func(i, j int) bool { return children[i].Pos() < children[j].Pos() })
+	return children
+}
@@ -0,0 +1,149 @@
+package source
+
+import (
+	"go/ast"
+	"go/token"
+	"testing"
+)
+
+const classifySource = `// Package classify has a bit of everything.
+package classify
+
+import (
+	"fmt"
+	"io"
+)
+
+// Shape is a shape.
+type Shape interface {
+	Area() float64
+}
+
+type Output interface {
+	io.Writer
+	Shape
+}
+
+type Rect struct {
+	W, H float64 ` + "`json:\"w\"`" + `
+	*Rect
+}
+
+// Area computes the area.
+func (r *Rect) Area() float64 { return r.W * r.H }
+
+func sum[T int | float64](xs ...T) (total T) {
+	for _, x := range xs {
+		total += x
+	}
+	return
+}
+
+func run(shapes []Shape, ch chan int) {
+	const limit = 10
+	var area float64
+	for i, s := range shapes {
+		if i > limit {
+			break
+		}
+		switch v := s.(type) {
+		case *Rect:
+			area += v.Area()
+		default:
+			fmt.Println(v)
+		}
+	}
+	select {
+	case n := <-ch:
+		_ = sum(n, 2)
+	default:
+	}
+	m := map[string][]int{"a": {1, 2}}
+	f := func() int { return len(m["a"][:1]) }
+	fmt.Printf("%v %d\n", &area, f())
+}
+`
+
+func TestBatchClassify(t *testing.T) {
+	fset, pkgs := loadTestPackages(t, testSource{path: "classify", files: map[string]string{"classify.go": classifySource}})
+	p := pkgs[0]
+	if len(p.errors) > 0 {
+		t.Fatal(p.errors)
+	}
+
+	// Classify every position of the file, in reverse order, plus a
+	// position outside of it.
+	tok := fset.File(p.syntax[0].Pos())
+	var positions []token.Pos
+	for offset := tok.Size(); offset >= 0; offset-- {
+		positions = append(positions, tok.Pos(offset))
+	}
+	positions = append(positions, token.NoPos)
+
+	results, err := BatchClassify(p, fset, positions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, pos := range positions {
+		var want ClassifyResult
+		if path, _, _ := doEnclosingInterval(p, fset, pos, pos); path != nil {
+			want.Path, want.Action = findInterestingNode(p, path)
+		}
+		got := results[i]
+		if len(got.Path) != len(want.Path) || got.Action != want.Action || len(got.Path) > 0 && got.Path[0] != want.Path[0] {
+			t.Errorf("%s: got %T of depth %d as %v, want %T of depth %d as %v", fset.Position(pos), first(got.Path), len(got.Path), got.Action, first(want.Path), len(want.Path), want.Action)
+		}
+	}
+
+	// The files of the package are not in another file set.
+	if _, err := BatchClassify(p, token.NewFileSet(), positions); err == nil {
+		t.Errorf("BatchClassify with another file set succeeded, want an error")
+	}
+	if _, _, err := doEnclosingInterval(p, token.NewFileSet(), positions[0], positions[0]); err == nil {
+		t.Errorf("doEnclosingInterval with another file set succeeded, want an error")
+	}
+}
+
+func first(path []ast.Node) ast.Node {
+	if len(path) == 0 {
+		return nil
+	}
+	return path[0]
+}
+
+// classifyPositions returns the package of classifySource and the
+// positions of all its identifiers.
+func classifyPositions(b *testing.B) (*token.FileSet, *testPackage, []token.Pos) {
+	fset, pkgs := loadTestPackages(b, testSource{path: "classify", files: map[string]string{"classify.go": classifySource}})
+	var positions []token.Pos
+	ast.Inspect(pkgs[0].syntax[0], func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok {
+			positions = append(positions, id.Pos())
+		}
+		return true
+	})
+	return fset, pkgs[0], positions
+}
+
+func BenchmarkClassifyLoop(b *testing.B) {
+	fset, p, positions := classifyPositions(b)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, pos := range positions {
+			path, _, _ := doEnclosingInterval(p, fset, pos, pos)
+			findInterestingNode(p, path)
+		}
+	}
+}
+
+func BenchmarkBatchClassify(b *testing.B) {
+	fset, p, positions := classifyPositions(b)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := BatchClassify(p, fset, positions); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
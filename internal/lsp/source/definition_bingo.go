@@ -0,0 +1,628 @@
+package source
+
+import (
+	"container/list"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// DefinitionInfo holds the declaration the syntax at a position resolves to.
+type DefinitionInfo struct {
+	// Object is the object denoted by the selected syntax.
+	Object types.Object
+	// Path is the path from the declaring identifier of Object up to its
+	// file, or nil when the declaring package has no syntax in the cache.
+	Path []ast.Node
+	// EmbeddedField is the embedded struct field whose name was selected.
+	// Object is then the embedded type, which the field name refers to.
+	EmbeddedField *types.Var
+	// Promotion lists the embedded fields, outermost first, through which
+	// a promoted field or method was selected.
+	Promotion []*types.Var
+	// CaseVar is the variable declared by a type switch guard for the
+	// case clause whose type was selected, such as v of type *T for *T in
+	// 'switch v := x.(type) { case *T: }'.
+	CaseVar *types.Var
+	// MethodExpr is the signature of a selected method expression, such
+	// as func(*T, int) for (*T).M, which takes the receiver as its first
+	// parameter.
+	MethodExpr *types.Signature
+	// Aliased is the type denoted by Object when it is an alias type
+	// name, such as int for ID in 'type ID = int', and nil otherwise.
+	Aliased types.Type
+	// Excluded is the name of the build-excluded file the declaration was
+	// found in by CrossPlatformDefinition. Object is nil in that case,
+	// since excluded files are not type-checked.
+	Excluded string
+}
+
+// Definition resolves the syntax at pos in pkg to the object it denotes and
+// locates that object's declaration. An identifier declared only in a file
+// excluded by build constraints is reported with a *PlatformGatedError; the
+// excluded files are then parsed on demand into fset, once per version.
+func Definition(pkg Package, fset *token.FileSet, pos token.Pos) (*DefinitionInfo, error) {
+	path, _, err := astPathEnclosingInterval(pkg, fset, pos, pos)
+	if err != nil {
+		return nil, err
+	}
+	if len(path) == 0 {
+		return nil, fmt.Errorf("no node found at %s", fset.Position(pos))
+	}
+
+	if err := checkLabelRef(pkg, fset, path); err != nil {
+		return nil, err
+	}
+	path = spreadArg(path, pos)
+
+	caseVar := typeSwitchCaseVar(pkg.GetTypesInfo(), path)
+	ident, _ := path[0].(*ast.Ident)
+	path, action := findInterestingNode(pkg, path)
+	obj, err := resolveObject(pkg, path, action)
+	if err != nil {
+		if ident != nil && pkg.GetTypesInfo().ObjectOf(ident) == nil {
+			if nodes, filename := findExcludedDecl(pkg, fset, ident.Name); nodes != nil {
+				return nil, &PlatformGatedError{Name: ident.Name, File: filename, Path: nodes}
+			}
+		}
+		return nil, err
+	}
+
+	info := &DefinitionInfo{Object: obj, CaseVar: caseVar}
+	if v, ok := obj.(*types.Var); ok && v.Embedded() {
+		// The name of an embedded field is also a reference to the
+		// embedded type; resolve to the type, but keep the field.
+		if id, ok := path[0].(*ast.Ident); ok && pkg.GetTypesInfo().Defs[id] == v {
+			info.EmbeddedField = v
+			info.Object = typeToObject(v.Type())
+		}
+	}
+	obj = info.Object
+	if tn, ok := obj.(*types.TypeName); ok && isAlias(tn) {
+		info.Aliased = types.Unalias(tn.Type())
+	}
+	if len(path) > 1 {
+		if sel, ok := path[1].(*ast.SelectorExpr); ok && sel.Sel == path[0] {
+			selection := pkg.GetTypesInfo().Selections[sel]
+			info.Promotion = promotionPath(selection)
+			if selection != nil && selection.Kind() == types.MethodExpr {
+				info.MethodExpr, _ = selection.Type().(*types.Signature)
+			}
+		}
+	}
+	if obj.Pkg() != nil && obj.Pos().IsValid() {
+		info.Path, _, _ = getObjectPathNode(pkg, fset, obj)
+	}
+	return info, nil
+}
+
+// promotionPath returns the embedded fields traversed by sel to reach its
+// field or method, following the selection index through any depth of
+// embedding.
+func promotionPath(sel *types.Selection) []*types.Var {
+	if sel == nil || len(sel.Index()) < 2 {
+		return nil
+	}
+
+	var fields []*types.Var
+	T := sel.Recv()
+	for _, i := range sel.Index()[:len(sel.Index())-1] {
+		st, ok := deref(T).Underlying().(*types.Struct)
+		if !ok {
+			return nil
+		}
+		f := st.Field(i)
+		fields = append(fields, f)
+		T = f.Type()
+	}
+	return fields
+}
+
+// checkLabelRef reports an error if path[0] is the label of a branch
+// statement that does not refer to a label of the enclosing function.
+// Labels are function-scoped, so a label of the same name declared in
+// another function must never be taken as the target.
+func checkLabelRef(pkg Package, fset *token.FileSet, path []ast.Node) error {
+	ident, ok := path[0].(*ast.Ident)
+	if !ok || len(path) < 2 {
+		return nil
+	}
+	if branch, ok := path[1].(*ast.BranchStmt); !ok || branch.Label != ident {
+		return nil
+	}
+
+	label, ok := pkg.GetTypesInfo().Uses[ident].(*types.Label)
+	if !ok {
+		return fmt.Errorf("label %s is not declared in the enclosing function", ident.Name)
+	}
+	declPath, _, err := astPathEnclosingInterval(pkg, fset, label.Pos(), label.Pos())
+	if err != nil {
+		return err
+	}
+	if enclosingFunc(declPath) != enclosingFunc(path) {
+		return fmt.Errorf("label %s is declared in another function", ident.Name)
+	}
+	return nil
+}
+
+// enclosingFunc returns the innermost function declaration or literal in
+// path, or nil if there is none.
+func enclosingFunc(path []ast.Node) ast.Node {
+	for _, n := range path {
+		switch n.(type) {
+		case *ast.FuncDecl, *ast.FuncLit:
+			return n
+		}
+	}
+	return nil
+}
+
+// spreadArg descends from a call to its last argument when pos is on the
+// "..." that spreads it, as in f(xs...), since the ellipsis has no node of
+// its own and otherwise denotes the whole call.
+func spreadArg(path []ast.Node, pos token.Pos) []ast.Node {
+	call, ok := path[0].(*ast.CallExpr)
+	if !ok || !call.Ellipsis.IsValid() || pos < call.Ellipsis || pos >= call.Ellipsis+token.Pos(len("...")) {
+		return path
+	}
+	return append([]ast.Node{call.Args[len(call.Args)-1]}, path...)
+}
+
+// typeSwitchCaseVar returns the implicit variable of the type switch case
+// clause whose list of types path passes through, or nil.
+func typeSwitchCaseVar(info *types.Info, path []ast.Node) *types.Var {
+	for i := 1; i < len(path); i++ {
+		clause, ok := path[i].(*ast.CaseClause)
+		if !ok {
+			continue
+		}
+		for _, x := range clause.List {
+			if x == path[i-1] {
+				v, _ := info.Implicits[clause].(*types.Var)
+				return v
+			}
+		}
+		return nil
+	}
+	return nil
+}
+
+// resolveObject returns the object denoted by path[0], as classified by
+// findInterestingNode.
+func resolveObject(pkg Package, path []ast.Node, action action) (types.Object, error) {
+	if len(path) == 0 || action == actionUnknown {
+		return nil, fmt.Errorf("no object for uninteresting node")
+	}
+
+	info := pkg.GetTypesInfo()
+	var obj types.Object
+	switch n := path[0].(type) {
+	case *ast.Ident:
+		obj = info.ObjectOf(n)
+	case *ast.SelectorExpr:
+		if sel, ok := info.Selections[n]; ok {
+			obj = sel.Obj()
+		} else {
+			obj = info.ObjectOf(n.Sel)
+		}
+	case *ast.CompositeLit:
+		// The type of an element literal may be elided, as in '[]T{{...}}';
+		// the implicit type is only recorded for the literal itself.
+		if n.Type == nil {
+			obj = typeToObject(info.TypeOf(n))
+		}
+	case *ast.StarExpr:
+		// Both the pointer type **T and the dereference **p denote the
+		// named type reached through the pointers.
+		obj = typeToObject(info.TypeOf(n))
+	case ast.Expr:
+		// A type expression such as []T denotes its named type, if any.
+		if action == actionType {
+			obj = typeToObject(info.TypeOf(n))
+		}
+	}
+	if obj == nil {
+		return nil, fmt.Errorf("no object for %T node", path[0])
+	}
+
+	return obj, nil
+}
+
+// VariantDefinition is like Definition, but when pos is not in a file of pkg,
+// such as in one of its in-package _test.go files, it resolves pos in the
+// variant of pkg cached in c whose files contain it, such as its test
+// variant, which shares its package path.
+func VariantDefinition(c ICache, pkg Package, fset *token.FileSet, pos token.Pos) (*DefinitionInfo, error) {
+	if path, _, _ := astPathEnclosingInterval(pkg, fset, pos, pos); path != nil {
+		return Definition(pkg, fset, pos)
+	}
+
+	var variant Package
+	contains := func(p Package) bool {
+		if p == pkg || p.GetTypes() == nil || p.GetTypes().Path() != pkg.GetTypes().Path() {
+			return false
+		}
+		if path, _, _ := astPathEnclosingInterval(p, fset, pos, pos); path != nil {
+			variant = p
+			return true
+		}
+		return false
+	}
+	if index, ok := c.(variantIndex); ok {
+		for _, p := range index.Variants(pkg.GetTypes().Path()) {
+			if contains(p) {
+				break
+			}
+		}
+	} else {
+		c.Walk(contains)
+	}
+	if variant == nil {
+		return nil, fmt.Errorf("no variant of package %s contains %s", pkg.PkgPath(), fset.Position(pos))
+	}
+	return Definition(variant, fset, pos)
+}
+
+// variantIndex is implemented by caches that keep the variants of a
+// package apart from it, out of their walk, since the variants share its
+// package path.
+type variantIndex interface {
+	Variants(pkgPath string) []Package
+}
+
+// walkVariants walks c like c.Walk, then the variants of the walked
+// packages if c keeps them apart, for searches that must see the test
+// files of the packages. The variants are visited once the walk is done,
+// since the cache may hold its lock during it.
+func walkVariants(c ICache, walkFunc WalkFunc) {
+	index, ok := c.(variantIndex)
+	if !ok {
+		c.Walk(walkFunc)
+		return
+	}
+
+	var paths []string
+	stopped := false
+	c.Walk(func(p Package) bool {
+		if walkFunc(p) {
+			stopped = true
+			return true
+		}
+		if p.GetTypes() != nil {
+			paths = append(paths, p.GetTypes().Path())
+		}
+		return false
+	})
+	if stopped {
+		return
+	}
+	for _, path := range paths {
+		for _, v := range index.Variants(path) {
+			if walkFunc(v) {
+				return
+			}
+		}
+	}
+}
+
+// TypeDefinition locates the declaration of the named type of expr, for
+// textDocument/typeDefinition. Pointer, slice, array, map and channel types
+// are unwrapped to their element type, so a []*T value leads to T. It
+// returns nil if the type of expr is not a named type, such as a builtin or
+// a type literal.
+func TypeDefinition(pkg Package, fset *token.FileSet, expr ast.Expr) (*DefinitionInfo, error) {
+	typ := pkg.GetTypesInfo().TypeOf(expr)
+	if typ == nil {
+		return nil, fmt.Errorf("no type for %s", types.ExprString(expr))
+	}
+
+	obj := namedTypeObject(typ)
+	if obj == nil || obj.Pkg() == nil {
+		// Builtin types such as int and error have no declaration.
+		return nil, nil
+	}
+	path, _, err := getObjectPathNode(pkg, fset, obj)
+	if err != nil {
+		return nil, err
+	}
+	return &DefinitionInfo{Object: obj, Path: path}, nil
+}
+
+// namedTypeObject returns the type name of typ, or of its element type if
+// typ is a pointer, slice, array, map or channel type, or nil if there is
+// none.
+func namedTypeObject(typ types.Type) *types.TypeName {
+	switch typ := types.Unalias(typ).(type) {
+	case *types.Named:
+		return typ.Obj()
+	case *types.Pointer:
+		return namedTypeObject(typ.Elem())
+	case *types.Slice:
+		return namedTypeObject(typ.Elem())
+	case *types.Array:
+		return namedTypeObject(typ.Elem())
+	case *types.Map:
+		return namedTypeObject(typ.Elem())
+	case *types.Chan:
+		return namedTypeObject(typ.Elem())
+	default:
+		return nil
+	}
+}
+
+// CommentLinkDefinition resolves the doc link at pos in a comment of pkg,
+// such as [DefaultTimeout] in a struct field comment
+// '// default: [DefaultTimeout]'. A link names a package-level object,
+// optionally qualified by an imported package and followed by a field or
+// method name: [Name], [Name.Member], [pkg.Name] or [pkg.Name.Member].
+func CommentLinkDefinition(pkg Package, fset *token.FileSet, pos token.Pos) (*DefinitionInfo, error) {
+	file, comment := commentAt(pkg, pos)
+	if comment == nil {
+		return nil, fmt.Errorf("no comment at %s", fset.Position(pos))
+	}
+	link := linkAt(comment.Text, int(pos-comment.Pos()))
+	if link == "" {
+		return nil, fmt.Errorf("no doc link at %s", fset.Position(pos))
+	}
+
+	obj := resolveLink(pkg, file, strings.Split(link, "."))
+	if obj == nil {
+		return nil, fmt.Errorf("doc link [%s] does not resolve", link)
+	}
+	info := &DefinitionInfo{Object: obj}
+	if obj.Pkg() != nil && obj.Pos().IsValid() {
+		info.Path, _, _ = getObjectPathNode(pkg, fset, obj)
+	}
+	return info, nil
+}
+
+// commentAt returns the comment of pkg containing pos, and its file.
+func commentAt(pkg Package, pos token.Pos) (*ast.File, *ast.Comment) {
+	for _, f := range pkg.GetSyntax() {
+		for _, group := range f.Comments {
+			if pos < group.Pos() || pos >= group.End() {
+				continue
+			}
+			for _, c := range group.List {
+				if c.Pos() <= pos && pos < c.End() {
+					return f, c
+				}
+			}
+		}
+	}
+	return nil, nil
+}
+
+// linkAt returns the text of the doc link enclosing offset in the comment
+// text, without its brackets, or "" if offset is not within a link.
+func linkAt(text string, offset int) string {
+	start := strings.LastIndexAny(text[:offset], "[]")
+	if start < 0 || text[start] != '[' {
+		return ""
+	}
+	end := strings.IndexAny(text[offset:], "[]")
+	if end < 0 || text[offset+end] != ']' {
+		return ""
+	}
+	link := text[start+1 : offset+end]
+	for _, part := range strings.Split(link, ".") {
+		if !isIdentifier(part) {
+			return ""
+		}
+	}
+	return link
+}
+
+// isIdentifier reports whether s is a Go identifier.
+func isIdentifier(s string) bool {
+	for i, r := range s {
+		if !unicode.IsLetter(r) && r != '_' && (i == 0 || !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// resolveLink resolves the dot-separated names of a doc link in file.
+func resolveLink(pkg Package, file *ast.File, names []string) types.Object {
+	scope := pkg.GetTypes().Scope()
+	if len(names) > 1 {
+		if imported := importedPackage(pkg.GetTypesInfo(), file, names[0]); imported != nil {
+			scope = imported.Scope()
+			names = names[1:]
+		}
+	}
+
+	obj := scope.Lookup(names[0])
+	switch {
+	case obj == nil || len(names) > 2:
+		return nil
+	case len(names) == 1:
+		return obj
+	}
+	if _, ok := obj.(*types.TypeName); !ok {
+		return nil
+	}
+	member, _, _ := types.LookupFieldOrMethod(obj.Type(), true, obj.Pkg(), names[1])
+	return member
+}
+
+// importedPackage returns the package imported by file under name, or nil.
+func importedPackage(info *types.Info, file *ast.File, name string) *types.Package {
+	for _, spec := range file.Imports {
+		var obj types.Object
+		if spec.Name != nil {
+			obj = info.Defs[spec.Name]
+		} else {
+			obj = info.Implicits[spec]
+		}
+		if pkgName, ok := obj.(*types.PkgName); ok && pkgName.Name() == name {
+			return pkgName.Imported()
+		}
+	}
+	return nil
+}
+
+// CrossPlatformDefinition is like Definition, but resolves identifiers
+// declared only in files of the package directory that were excluded from
+// the build, such as the _windows.go variant of a function when running on
+// Linux, to their declaration in that file instead of failing with a
+// PlatformGatedError.
+func CrossPlatformDefinition(pkg Package, fset *token.FileSet, pos token.Pos) (*DefinitionInfo, error) {
+	def, err := Definition(pkg, fset, pos)
+	if gated, ok := err.(*PlatformGatedError); ok {
+		return &DefinitionInfo{Path: gated.Path, Excluded: gated.File}, nil
+	}
+	return def, err
+}
+
+// PlatformGatedError is returned by Definition for an identifier that is
+// not declared in the files of the build, but is declared at package level
+// in a file of the package directory excluded by build constraints.
+type PlatformGatedError struct {
+	Name string
+	// File is the excluded file declaring Name.
+	File string
+	// Path is the path from the declaring identifier up to its file, as
+	// parsed from File.
+	Path []ast.Node
+}
+
+func (e *PlatformGatedError) Error() string {
+	return fmt.Sprintf("%s is only declared in %s, which is excluded from the build", e.Name, filepath.Base(e.File))
+}
+
+// findExcludedDecl parses the Go files of pkg's directory that are not part
+// of pkg and returns the path to the first package-level identifier declared
+// with name, along with the file declaring it.
+func findExcludedDecl(pkg Package, fset *token.FileSet, name string) ([]ast.Node, string) {
+	filenames := pkg.GetFilenames()
+	if len(filenames) == 0 {
+		return nil, ""
+	}
+
+	loaded := make(map[string]bool, len(filenames))
+	for _, filename := range filenames {
+		loaded[filename] = true
+	}
+
+	dir := filepath.Dir(filenames[0])
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, ""
+	}
+
+	for _, fi := range fis {
+		filename := filepath.Join(dir, fi.Name())
+		if fi.IsDir() || loaded[filename] || !strings.HasSuffix(filename, ".go") || strings.HasSuffix(filename, "_test.go") {
+			continue
+		}
+
+		f := parseExcludedFile(fset, filename, fi)
+		if f == nil || f.Name.Name != pkg.GetTypes().Name() {
+			continue
+		}
+		if id := packageLevelIdent(f, name); id != nil {
+			nodes, _, _ := pathEnclosingIntervalLimited(f, id.Pos(), id.End())
+			return nodes, filename
+		}
+	}
+
+	return nil, ""
+}
+
+// maxExcludedFiles bounds the number of files cached by parseExcludedFile.
+const maxExcludedFiles = 256
+
+// excludedFiles caches the files parsed by findExcludedDecl by file set and
+// file name, since a file set never releases the files added to it: each
+// excluded file is added once, and again only when it changes on disk. The
+// least recently used files are evicted beyond maxExcludedFiles, which
+// releases the file sets of dropped views along with their syntax.
+var excludedFiles = struct {
+	sync.Mutex
+	m map[excludedKey]*list.Element
+	// lru holds the *excludedFile values, most recently used first.
+	lru *list.List
+}{m: make(map[excludedKey]*list.Element), lru: list.New()}
+
+type excludedKey struct {
+	fset     *token.FileSet
+	filename string
+}
+
+type excludedFile struct {
+	key     excludedKey
+	modTime time.Time
+	size    int64
+	// file is nil if the file does not parse.
+	file *ast.File
+}
+
+// parseExcludedFile returns the syntax of filename, whose file info is fi,
+// parsed into fset, or nil if it does not parse.
+func parseExcludedFile(fset *token.FileSet, filename string, fi os.FileInfo) *ast.File {
+	excludedFiles.Lock()
+	defer excludedFiles.Unlock()
+
+	key := excludedKey{fset, filename}
+	if e := excludedFiles.m[key]; e != nil {
+		if ef := e.Value.(*excludedFile); ef.modTime.Equal(fi.ModTime()) && ef.size == fi.Size() {
+			excludedFiles.lru.MoveToFront(e)
+			return ef.file
+		}
+		excludedFiles.lru.Remove(e)
+		delete(excludedFiles.m, key)
+	}
+
+	f, err := parser.ParseFile(fset, filename, nil, parser.ParseComments)
+	if err != nil {
+		f = nil
+	}
+	excludedFiles.m[key] = excludedFiles.lru.PushFront(&excludedFile{key: key, modTime: fi.ModTime(), size: fi.Size(), file: f})
+	for excludedFiles.lru.Len() > maxExcludedFiles {
+		e := excludedFiles.lru.Back()
+		excludedFiles.lru.Remove(e)
+		delete(excludedFiles.m, e.Value.(*excludedFile).key)
+	}
+	return f
+}
+
+// packageLevelIdent returns the identifier declaring name at package level
+// in f, or nil if there is none.
+func packageLevelIdent(f *ast.File, name string) *ast.Ident {
+	for _, decl := range f.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			if decl.Recv == nil && decl.Name.Name == name {
+				return decl.Name
+			}
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					if spec.Name.Name == name {
+						return spec.Name
+					}
+				case *ast.ValueSpec:
+					for _, id := range spec.Names {
+						if id.Name == name {
+							return id
+						}
+					}
+				}
+			}
+		}
+	}
+
+	return nil
+}
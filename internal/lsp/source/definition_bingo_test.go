@@ -0,0 +1,1372 @@
+package source
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// checkDefinition resolves the marker in file of pkg and checks that it is
+// declared at declMarker in declFile of declPkg.
+func checkDefinition(t *testing.T, fset *token.FileSet, pkg *testPackage, file, marker string, declPkg *testPackage, declFile, declMarker string) *DefinitionInfo {
+	t.Helper()
+
+	def, err := Definition(pkg, fset, testPos(t, fset, pkg, file, marker))
+	if err != nil {
+		t.Fatalf("%s: %v", marker, err)
+	}
+	want := testPos(t, fset, declPkg, declFile, declMarker)
+	if def.Object.Pos() != want {
+		t.Errorf("%s: resolved to %s at %s, want %s", marker, def.Object, fset.Position(def.Object.Pos()), fset.Position(want))
+	}
+	if len(def.Path) == 0 {
+		t.Errorf("%s: no declaration path", marker)
+	} else if id, ok := def.Path[0].(*ast.Ident); !ok || id.Pos() != want {
+		t.Errorf("%s: declaration path starts at %T, want identifier at %s", marker, def.Path[0], fset.Position(want))
+	}
+	return def
+}
+
+func TestDefinitionEmbeddedInterface(t *testing.T) {
+	fset, pkgs := loadTestPackages(t,
+		testSource{path: "rd", files: map[string]string{"rd.go": `package rd
+
+type Reader interface {
+	Read(p []byte) (int, error)
+}
+`}},
+		testSource{path: "use", files: map[string]string{"use.go": `package use
+
+import "rd"
+
+type T struct{ rd.Reader }
+
+func f(t T) {
+	t.Read(nil)
+}
+`}},
+	)
+	rd, use := pkgs[0], pkgs[1]
+
+	checkDefinition(t, fset, use, "use.go", "rd.^Reader }", rd, "rd.go", "Reader interface")
+	checkDefinition(t, fset, use, "use.go", "t.^Read(nil)", rd, "rd.go", "Read(p")
+}
+
+func TestCrossPlatformDefinition(t *testing.T) {
+	dir, err := ioutil.TempDir("", "crossplatform")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"use_linux.go": `package plat
+
+func use() {
+	helper()
+}
+`,
+		"helper_windows.go": `package plat
+
+func helper() {}
+`,
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Only the Linux file is part of the build.
+	fset, pkgs := loadTestPackages(t, testSource{path: "plat", dir: dir, files: map[string]string{"use_linux.go": files["use_linux.go"]}})
+	pos := testPos(t, fset, pkgs[0], "use_linux.go", "helper()")
+
+	if _, err := Definition(pkgs[0], fset, pos); err == nil {
+		t.Fatal("Definition resolved a symbol declared only in an excluded file")
+	}
+	def, err := CrossPlatformDefinition(pkgs[0], fset, pos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(dir, "helper_windows.go"); def.Excluded != want {
+		t.Errorf("got declaring file %q, want %q", def.Excluded, want)
+	}
+	id, ok := def.Path[0].(*ast.Ident)
+	if !ok || id.Name != "helper" {
+		t.Fatalf("declaration path starts at %T, want helper identifier", def.Path[0])
+	}
+	if p := fset.Position(id.Pos()); p.Line != 3 || p.Column != 6 {
+		t.Errorf("got declaration at %s, want 3:6", p)
+	}
+
+	// The excluded file is added to the file set only once.
+	base := fset.Base()
+	def2, err := CrossPlatformDefinition(pkgs[0], fset, pos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fset.Base() != base || def2.Path[0] != def.Path[0] {
+		t.Errorf("an unchanged excluded file was parsed again")
+	}
+
+	// It is parsed again once it changes.
+	if err := ioutil.WriteFile(filepath.Join(dir, "helper_windows.go"), []byte("package plat\n\n// helper helps.\nfunc helper() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	def, err = CrossPlatformDefinition(pkgs[0], fset, pos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := fset.Position(def.Path[0].Pos()); p.Line != 4 {
+		t.Errorf("got declaration at %s after the change, want line 4", p)
+	}
+}
+
+func TestParseExcludedFileEviction(t *testing.T) {
+	dir, err := ioutil.TempDir("", "excluded")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fset := token.NewFileSet()
+	parse := func(i int) *ast.File {
+		filename := filepath.Join(dir, fmt.Sprintf("f%d.go", i))
+		if _, err := os.Stat(filename); os.IsNotExist(err) {
+			if err := ioutil.WriteFile(filename, []byte("package p\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		fi, err := os.Stat(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parseExcludedFile(fset, filename, fi)
+	}
+
+	first := parse(0)
+	for i := 1; i <= maxExcludedFiles; i++ {
+		parse(i)
+	}
+	excludedFiles.Lock()
+	n := len(excludedFiles.m)
+	excludedFiles.Unlock()
+	if n > maxExcludedFiles {
+		t.Errorf("%d excluded files cached, want at most %d", n, maxExcludedFiles)
+	}
+
+	// The least recently used file was evicted, so it is parsed again.
+	if parse(0) == first {
+		t.Errorf("the least recently used excluded file was not evicted")
+	}
+	// The most recently used one is still cached.
+	last := parse(maxExcludedFiles)
+	if parse(maxExcludedFiles) != last {
+		t.Errorf("a recently used excluded file was parsed again")
+	}
+}
+
+func TestDefinitionEmbeddedField(t *testing.T) {
+	fset, pkgs := loadTestPackages(t, testSource{path: "embed", files: map[string]string{"embed.go": `package embed
+
+type B struct{ V int }
+
+type A struct {
+	B
+}
+
+func f(a A) int { return a.B.V }
+`}})
+	p := pkgs[0]
+
+	def := checkDefinition(t, fset, p, "embed.go", "\t^B\n", p, "embed.go", "B struct")
+	if def.EmbeddedField == nil || def.EmbeddedField.Name() != "B" || def.EmbeddedField.Pos() != testPos(t, fset, p, "embed.go", "\t^B\n") {
+		t.Errorf("got embedded field %v, want field B of A", def.EmbeddedField)
+	}
+
+	// A use of the promoted field resolves to the field itself.
+	def, err := Definition(p, fset, testPos(t, fset, p, "embed.go", "a.^B.V"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := def.Object.(*types.Var); !ok || !v.Embedded() || def.EmbeddedField != nil {
+		t.Errorf("got %v, want the embedded field B", def.Object)
+	}
+}
+
+func TestDefinitionAnonymousStructFields(t *testing.T) {
+	fset, pkgs := loadTestPackages(t, testSource{path: "anon", files: map[string]string{"anon.go": `package anon
+
+func f() int {
+	x := struct{ Inner struct{ V int } }{}
+	return x.Inner.V
+}
+`}})
+	p := pkgs[0]
+
+	checkDefinition(t, fset, p, "anon.go", "x.^Inner.V", p, "anon.go", "Inner struct")
+	checkDefinition(t, fset, p, "anon.go", "Inner.^V", p, "anon.go", "V int")
+	// The field declarations resolve to themselves.
+	checkDefinition(t, fset, p, "anon.go", "Inner struct", p, "anon.go", "Inner struct")
+	checkDefinition(t, fset, p, "anon.go", "V int", p, "anon.go", "V int")
+}
+
+func TestDefinitionLabelScope(t *testing.T) {
+	fset, pkgs := loadTestPackages(t, testSource{path: "labels", files: map[string]string{"labels.go": `package labels
+
+func first() {
+L:
+	for {
+		break L
+	}
+}
+
+func second() {
+L:
+	for {
+		continue L
+	}
+}
+
+func third() {
+	goto L
+}
+`}})
+	p := pkgs[0]
+
+	def := checkDefinition(t, fset, p, "labels.go", "break ^L", p, "labels.go", "L:\n\tfor {\n\t\tbreak")
+	if _, ok := def.Object.(*types.Label); !ok {
+		t.Errorf("got %T, want *types.Label", def.Object)
+	}
+	checkDefinition(t, fset, p, "labels.go", "continue ^L", p, "labels.go", "L:\n\tfor {\n\t\tcontinue")
+
+	if def, err := Definition(p, fset, testPos(t, fset, p, "labels.go", "goto ^L")); err == nil {
+		t.Errorf("goto L in third resolved to %v at %s, want an error", def.Object, fset.Position(def.Object.Pos()))
+	}
+}
+
+func TestDefinitionPromotedMethod(t *testing.T) {
+	fset, pkgs := loadTestPackages(t, testSource{path: "promo", files: map[string]string{"promo.go": `package promo
+
+type C struct{}
+
+func (C) M() {}
+
+type B struct{ *C }
+
+type A struct{ B }
+
+func f(a A) {
+	a.M()
+}
+`}})
+	p := pkgs[0]
+
+	def := checkDefinition(t, fset, p, "promo.go", "a.^M()", p, "promo.go", "M() {}")
+	var got []string
+	for _, f := range def.Promotion {
+		got = append(got, f.Name())
+	}
+	if strings.Join(got, ".") != "B.C" {
+		t.Errorf("got promotion path %v, want B.C", got)
+	}
+}
+
+func TestDefinitionVendored(t *testing.T) {
+	fset, pkgs := loadTestPackages(t,
+		testSource{path: "proj/vendor/dep", files: map[string]string{"dep.go": `package dep
+
+func Helper() {}
+`}},
+		testSource{path: "proj", files: map[string]string{"proj.go": `package proj
+
+import "dep"
+
+func f() {
+	dep.Helper()
+}
+`}},
+	)
+	dep, proj := pkgs[0], pkgs[1]
+
+	checkDefinition(t, fset, proj, "proj.go", "dep.^Helper()", dep, "dep.go", "Helper()")
+}
+
+func TestDefinitionSwitchCaseConst(t *testing.T) {
+	fset, pkgs := loadTestPackages(t, testSource{path: "status", files: map[string]string{"status.go": `package status
+
+const (
+	StatusOK       = 200
+	StatusNotFound = 404
+)
+
+func text(code int) string {
+	switch code {
+	case StatusOK:
+		return "OK"
+	case StatusNotFound, 410:
+		return "Not Found"
+	}
+	return ""
+}
+`}})
+	p := pkgs[0]
+
+	checkDefinition(t, fset, p, "status.go", "case ^StatusOK:", p, "status.go", "StatusOK  ")
+	checkDefinition(t, fset, p, "status.go", "case ^StatusNotFound,", p, "status.go", "StatusNotFound =")
+	// The keyword of a single-expression clause stands for the expression.
+	checkDefinition(t, fset, p, "status.go", "case StatusOK:", p, "status.go", "StatusOK  ")
+}
+
+func TestDefinitionBuiltinTypeArgument(t *testing.T) {
+	fset, pkgs := loadTestPackages(t, testSource{path: "alloc", files: map[string]string{"alloc.go": `package alloc
+
+type MyStruct struct{}
+
+type K string
+
+type V int
+
+func f() {
+	_ = new(MyStruct)
+	_ = make(map[K]V)
+}
+`}})
+	p := pkgs[0]
+
+	checkDefinition(t, fset, p, "alloc.go", "new(^MyStruct)", p, "alloc.go", "MyStruct struct")
+	checkDefinition(t, fset, p, "alloc.go", "new(MyStruct)", p, "alloc.go", "MyStruct struct")
+	checkDefinition(t, fset, p, "alloc.go", "map[^K]V", p, "alloc.go", "K string")
+	checkDefinition(t, fset, p, "alloc.go", "map[K]^V", p, "alloc.go", "V int")
+}
+
+func TestDefinitionConstraintUnion(t *testing.T) {
+	fset, pkgs := loadTestPackages(t, testSource{path: "union", files: map[string]string{"union.go": `package union
+
+type Small int8
+
+type Name string
+
+func Max[T Small | Name | ~int](a, b T) T {
+	if a > b {
+		return a
+	}
+	return b
+}
+`}})
+	p := pkgs[0]
+
+	checkDefinition(t, fset, p, "union.go", "[T ^Small |", p, "union.go", "Small int8")
+	checkDefinition(t, fset, p, "union.go", "| ^Name |", p, "union.go", "Name string")
+
+	for _, marker := range []string{"| ~^int]", "| ^~int]"} {
+		def, err := Definition(p, fset, testPos(t, fset, p, "union.go", marker))
+		if err != nil {
+			t.Fatalf("%s: %v", marker, err)
+		}
+		if def.Object != types.Universe.Lookup("int") {
+			t.Errorf("%s: got %v, want the int type", marker, def.Object)
+		}
+	}
+
+	path, _, _ := doEnclosingInterval(p, fset, testPos(t, fset, p, "union.go", "Small ^| Name"), testPos(t, fset, p, "union.go", "Small ^| Name"))
+	if _, action := findInterestingNode(p, path); action != actionType {
+		t.Errorf("union classified as %v, want actionType", action)
+	}
+}
+
+func TestDefinitionReceiverType(t *testing.T) {
+	fset, pkgs := loadTestPackages(t, testSource{path: "recv", files: map[string]string{"recv.go": `package recv
+
+type MyType struct{}
+
+func (t *MyType) M() {}
+
+func (MyType) N() {}
+`}})
+	p := pkgs[0]
+
+	for _, marker := range []string{"(t *^MyType)", "(t ^*MyType)", "(^MyType) N"} {
+		checkDefinition(t, fset, p, "recv.go", marker, p, "recv.go", "MyType struct")
+		pos := testPos(t, fset, p, "recv.go", marker)
+		path, _, _ := doEnclosingInterval(p, fset, pos, pos)
+		if _, action := findInterestingNode(p, path); action != actionType {
+			t.Errorf("%s: declaration classified as %v, want actionType", marker, action)
+		}
+	}
+	// The receiver name still denotes the receiver variable.
+	checkDefinition(t, fset, p, "recv.go", "(^t *MyType)", p, "recv.go", "t *MyType")
+}
+
+func TestDefinitionForwardReference(t *testing.T) {
+	fset, pkgs := loadTestPackages(t, testSource{path: "forward", files: map[string]string{"forward.go": `package forward
+
+func start() bool {
+	return isEven(4)
+}
+
+func isOdd(n int) bool {
+	if n == 0 {
+		return false
+	}
+	return isEven(n - 1)
+}
+
+func isEven(n int) bool {
+	if n == 0 {
+		return true
+	}
+	return isOdd(n - 1)
+}
+`}})
+	p := pkgs[0]
+
+	checkDefinition(t, fset, p, "forward.go", "return ^isEven(4)", p, "forward.go", "isEven(n int)")
+	checkDefinition(t, fset, p, "forward.go", "return ^isEven(n - 1)", p, "forward.go", "isEven(n int)")
+	checkDefinition(t, fset, p, "forward.go", "return ^isOdd(n - 1)", p, "forward.go", "isOdd(n int)")
+}
+
+func TestDefinitionElidedCompositeLiteral(t *testing.T) {
+	fset, pkgs := loadTestPackages(t, testSource{path: "elided", files: map[string]string{"elided.go": `package elided
+
+type Point struct{ X, Y int }
+
+var points = []Point{{X: 1, Y: 2}, {3, 4}}
+
+var ptrs = []*Point{{Y: 5}}
+
+var grid = map[string][]Point{"a": {{X: 6}}}
+`}})
+	p := pkgs[0]
+
+	checkDefinition(t, fset, p, "elided.go", "{{^X: 1", p, "elided.go", "X, Y")
+	checkDefinition(t, fset, p, "elided.go", "^{3, 4}", p, "elided.go", "Point struct")
+	checkDefinition(t, fset, p, "elided.go", "{{^Y: 5", p, "elided.go", "Y int")
+	checkDefinition(t, fset, p, "elided.go", "^{Y: 5", p, "elided.go", "Point struct")
+	checkDefinition(t, fset, p, "elided.go", "{{^X: 6", p, "elided.go", "X, Y")
+}
+
+func TestDefinitionDotImportedMethod(t *testing.T) {
+	fset, pkgs := loadTestPackages(t,
+		testSource{path: "buf", files: map[string]string{"buf.go": `package buf
+
+type Buffer struct{ data []byte }
+
+func New() *Buffer { return &Buffer{} }
+
+func (b *Buffer) Write(p []byte) { b.data = append(b.data, p...) }
+`}},
+		testSource{path: "use", files: map[string]string{"use.go": `package use
+
+import . "buf"
+
+func f() {
+	var b Buffer
+	b.Write(nil)
+	New().Write(nil)
+}
+`}},
+	)
+	buf, use := pkgs[0], pkgs[1]
+
+	checkDefinition(t, fset, use, "use.go", "var b ^Buffer", buf, "buf.go", "Buffer struct")
+	checkDefinition(t, fset, use, "use.go", "b.^Write(nil)", buf, "buf.go", "Write(p")
+	checkDefinition(t, fset, use, "use.go", "^New()", buf, "buf.go", "New()")
+	checkDefinition(t, fset, use, "use.go", "New().^Write", buf, "buf.go", "Write(p")
+}
+
+func TestDefinitionNestedLabels(t *testing.T) {
+	fset, pkgs := loadTestPackages(t, testSource{path: "nested", files: map[string]string{"nested.go": `package nested
+
+func find(grid [][]int, v int) bool {
+Outer:
+	for _, row := range grid {
+	Inner:
+		for _, x := range row {
+			if x < 0 {
+				continue Outer
+			}
+			if x == 0 {
+				continue Inner
+			}
+			if x == v {
+				return true
+			}
+		}
+	}
+	return false
+}
+`}})
+	p := pkgs[0]
+
+	checkDefinition(t, fset, p, "nested.go", "continue ^Outer", p, "nested.go", "Outer:")
+	checkDefinition(t, fset, p, "nested.go", "continue ^Inner", p, "nested.go", "Inner:")
+}
+
+func TestDefinitionTypeSwitchCase(t *testing.T) {
+	fset, pkgs := loadTestPackages(t, testSource{path: "tswitch", files: map[string]string{"tswitch.go": `package tswitch
+
+type Foo struct{ N int }
+
+type Bar int
+
+func f(x interface{}) int {
+	switch v := x.(type) {
+	case *Foo:
+		return v.N
+	case Bar:
+		return int(v)
+	case int, string:
+		_ = v
+	}
+	return 0
+}
+`}})
+	p := pkgs[0]
+
+	for _, test := range []struct {
+		marker, decl, caseType string
+	}{
+		{"case *^Foo:", "Foo struct", "*tswitch.Foo"},
+		{"case ^*Foo:", "Foo struct", "*tswitch.Foo"},
+		{"case ^Bar:", "Bar int", "tswitch.Bar"},
+	} {
+		def := checkDefinition(t, fset, p, "tswitch.go", test.marker, p, "tswitch.go", test.decl)
+		if def.CaseVar == nil || def.CaseVar.Name() != "v" || def.CaseVar.Type().String() != test.caseType {
+			t.Errorf("%s: got case variable %v, want v of type %s", test.marker, def.CaseVar, test.caseType)
+		}
+	}
+
+	// Uses of the guard variable have the type of their case.
+	for marker, want := range map[string]string{
+		"return ^v.N": "*tswitch.Foo",
+		"int(^v)":     "tswitch.Bar",
+		"_ = ^v":      "interface{}",
+	} {
+		def, err := Definition(p, fset, testPos(t, fset, p, "tswitch.go", marker))
+		if err != nil {
+			t.Fatalf("%s: %v", marker, err)
+		}
+		if got := def.Object.Type().String(); got != want {
+			t.Errorf("%s: got type %s, want %s", marker, got, want)
+		}
+	}
+}
+
+func TestDefinitionInterfaceMethodCall(t *testing.T) {
+	fset, pkgs := loadTestPackages(t,
+		testSource{path: "stream", files: map[string]string{"stream.go": `package stream
+
+type Reader interface {
+	Read(p []byte) (int, error)
+}
+
+type File struct{}
+
+func (*File) Read(p []byte) (int, error) { return 0, nil }
+`}},
+		testSource{path: "use", files: map[string]string{"use.go": `package use
+
+import "stream"
+
+func f() {
+	var r stream.Reader = &stream.File{}
+	r.Read(nil)
+}
+`}},
+	)
+	stream, use := pkgs[0], pkgs[1]
+
+	// The call resolves statically to the interface method, not to the
+	// method of the dynamic type.
+	def := checkDefinition(t, fset, use, "use.go", "r.^Read(nil)", stream, "stream.go", "Read(p []byte) (int, error)\n")
+	if recv := def.Object.(*types.Func).Type().(*types.Signature).Recv(); recv == nil || !types.IsInterface(recv.Type()) {
+		t.Errorf("got method with receiver %v, want the interface method", recv)
+	}
+}
+
+func TestDefinitionPlatformGated(t *testing.T) {
+	dir, err := ioutil.TempDir("", "platformgated")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"run_linux.go": `package gated
+
+func run() {
+	setup()
+	limit := maxProcs
+	_ = limit
+	missing()
+}
+
+func setup() {}
+`,
+		"procs_windows.go": `package gated
+
+const maxProcs = 64
+`,
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fset, pkgs := loadTestPackages(t, testSource{path: "gated", dir: dir, files: map[string]string{"run_linux.go": files["run_linux.go"]}})
+	p := pkgs[0]
+
+	// Symbols of the build resolve normally within the function body.
+	checkDefinition(t, fset, p, "run_linux.go", "\t^setup()", p, "run_linux.go", "setup() {}")
+
+	_, err = Definition(p, fset, testPos(t, fset, p, "run_linux.go", "limit := ^maxProcs"))
+	gated, ok := err.(*PlatformGatedError)
+	if !ok {
+		t.Fatalf("got error %v, want *PlatformGatedError", err)
+	}
+	if want := filepath.Join(dir, "procs_windows.go"); gated.Name != "maxProcs" || gated.File != want {
+		t.Errorf("got %s declared in %s, want maxProcs declared in %s", gated.Name, gated.File, want)
+	}
+
+	// Looking up the gated symbol again does not grow the file set.
+	base := fset.Base()
+	for i := 0; i < 3; i++ {
+		if _, err := Definition(p, fset, testPos(t, fset, p, "run_linux.go", "limit := ^maxProcs")); err == nil {
+			t.Fatal("Definition resolved a symbol declared only in an excluded file")
+		}
+	}
+	if fset.Base() != base {
+		t.Errorf("file set grew from base %d to %d over repeated lookups", base, fset.Base())
+	}
+
+	_, err = Definition(p, fset, testPos(t, fset, p, "run_linux.go", "^missing()"))
+	if _, ok := err.(*PlatformGatedError); err == nil || ok {
+		t.Errorf("got error %v for an undeclared symbol, want a plain error", err)
+	}
+}
+
+func TestDefinitionImmediatelyInvokedFunc(t *testing.T) {
+	fset, pkgs := loadTestPackages(t, testSource{path: "iife", files: map[string]string{"iife.go": `package iife
+
+type Config struct{ Name string }
+
+func defaultName() string { return "x" }
+
+var cfg = func() *Config {
+	name := defaultName()
+	return &Config{Name: name}
+}()
+`}})
+	p := pkgs[0]
+
+	checkDefinition(t, fset, p, "iife.go", "func() *^Config", p, "iife.go", "Config struct")
+	checkDefinition(t, fset, p, "iife.go", "func() ^*Config", p, "iife.go", "Config struct")
+	checkDefinition(t, fset, p, "iife.go", "name := ^defaultName()", p, "iife.go", "defaultName() string")
+	checkDefinition(t, fset, p, "iife.go", "{Name: ^name}", p, "iife.go", "name :=")
+}
+
+func TestDefinitionPointerChain(t *testing.T) {
+	fset, pkgs := loadTestPackages(t, testSource{path: "ptr", files: map[string]string{"ptr.go": `package ptr
+
+type MyType struct{ Field int }
+
+func (*MyType) Method() {}
+
+func f(pp **MyType) int {
+	(*pp).Method()
+	return (**pp).Field
+}
+`}})
+	p := pkgs[0]
+
+	for _, marker := range []string{"pp ^**MyType", "pp *^*MyType", "pp **^MyType", "(^**pp).Field", "(*^*pp).Field", "(^*pp).Method"} {
+		checkDefinition(t, fset, p, "ptr.go", marker, p, "ptr.go", "MyType struct")
+	}
+	checkDefinition(t, fset, p, "ptr.go", "(**pp).^Field", p, "ptr.go", "Field int")
+	checkDefinition(t, fset, p, "ptr.go", "(*pp).^Method", p, "ptr.go", "Method() {}")
+	checkDefinition(t, fset, p, "ptr.go", "(**^pp)", p, "ptr.go", "pp **MyType")
+}
+
+func TestDefinitionIndexExpr(t *testing.T) {
+	fset, pkgs := loadTestPackages(t, testSource{path: "index", files: map[string]string{"index.go": `package index
+
+type List[T any] struct{ items []T }
+
+type Pair[K comparable, V any] struct{}
+
+func Map[T, U any](xs []T, f func(T) U) []U { return nil }
+
+func f(arr [4]int, i int) int {
+	var l List[int]
+	var p Pair[string, int]
+	_, _ = l, p
+	_ = Map[int, string](nil, nil)
+	return arr[i]
+}
+`}})
+	p := pkgs[0]
+
+	checkDefinition(t, fset, p, "index.go", "return ^arr[i]", p, "index.go", "arr [4]int")
+	checkDefinition(t, fset, p, "index.go", "arr[^i]", p, "index.go", "i int")
+	if _, err := Definition(p, fset, testPos(t, fset, p, "index.go", "arr^[i]")); err == nil {
+		t.Error("index expression resolved to an object")
+	}
+
+	for _, test := range []struct{ marker, decl string }{
+		{"var l ^List[int]", "List[T any]"},
+		{"var l List^[int]", "List[T any]"},
+		{"var p Pair^[string, int]", "Pair[K"},
+		{"_ = Map^[int, string]", "Map[T, U"},
+		{"_ = ^Map[int, string]", "Map[T, U"},
+	} {
+		checkDefinition(t, fset, p, "index.go", test.marker, p, "index.go", test.decl)
+	}
+
+	pos := testPos(t, fset, p, "index.go", "var l List^[int]")
+	path, _, _ := doEnclosingInterval(p, fset, pos, pos)
+	if _, action := findInterestingNode(p, path); action != actionType {
+		t.Errorf("generic type instantiation classified as %v, want actionType", action)
+	}
+}
+
+func TestDefinitionMethodExpression(t *testing.T) {
+	fset, pkgs := loadTestPackages(t, testSource{path: "mexpr", files: map[string]string{"mexpr.go": `package mexpr
+
+type T struct{ name string }
+
+func (t *T) Method(n int) string { return t.name }
+
+func f(t *T) string {
+	fn := (*T).Method
+	return fn(t, 1)
+}
+`}})
+	p := pkgs[0]
+
+	def := checkDefinition(t, fset, p, "mexpr.go", "(*T).^Method", p, "mexpr.go", "Method(n int)")
+	if def.MethodExpr == nil || def.MethodExpr.String() != "func(t *mexpr.T, n int) string" {
+		t.Errorf("got method expression signature %v, want func(t *mexpr.T, n int) string", def.MethodExpr)
+	}
+	if def := checkDefinition(t, fset, p, "mexpr.go", "return ^fn(t, 1)", p, "mexpr.go", "fn :="); def.Object.Type().String() != "func(t *mexpr.T, n int) string" {
+		t.Errorf("got variable of type %s, want the method expression type", def.Object.Type())
+	}
+	checkDefinition(t, fset, p, "mexpr.go", "(*^T).Method", p, "mexpr.go", "T struct")
+}
+
+func TestDefinitionTypeArgument(t *testing.T) {
+	fset, pkgs := loadTestPackages(t, testSource{path: "typearg", files: map[string]string{"typearg.go": `package typearg
+
+type MyType struct{}
+
+type Key string
+
+type Container[T any] struct{ items []T }
+
+type Table[K comparable, V any] map[K]V
+
+func Zero[T any]() (t T) { return }
+
+func f(m map[Key]int, key Key) int {
+	var c Container[MyType]
+	var t Table[Key, *MyType]
+	_, _ = c, t
+	_ = Zero[MyType]()
+	return m[key]
+}
+`}})
+	p := pkgs[0]
+
+	for _, marker := range []string{"Container[^MyType]", "Table[Key, *^MyType]", "Zero[^MyType]"} {
+		checkDefinition(t, fset, p, "typearg.go", marker, p, "typearg.go", "MyType struct")
+		pos := testPos(t, fset, p, "typearg.go", marker)
+		path, _, _ := doEnclosingInterval(p, fset, pos, pos)
+		if _, action := findInterestingNode(p, path); action != actionType {
+			t.Errorf("%s: type argument classified as %v, want actionType", marker, action)
+		}
+	}
+	checkDefinition(t, fset, p, "typearg.go", "Table[^Key,", p, "typearg.go", "Key string")
+
+	// The index of a map is a value, not a type argument.
+	def := checkDefinition(t, fset, p, "typearg.go", "m[^key]", p, "typearg.go", "key Key")
+	if _, ok := def.Object.(*types.Var); !ok {
+		t.Errorf("map index resolved to %T, want *types.Var", def.Object)
+	}
+}
+
+func TestDefinitionNestedClosures(t *testing.T) {
+	fset, pkgs := loadTestPackages(t, testSource{path: "closure", files: map[string]string{"closure.go": `package closure
+
+func counter() func() func() func() int {
+	count := 0
+	return func() func() func() int {
+		return func() func() int {
+			return func() int {
+				count++
+				return count
+			}
+		}
+	}
+}
+
+func shadow() int {
+	count := 1
+	return func() int {
+		count := 2
+		return func() int {
+			return count
+		}()
+	}()
+}
+`}})
+	p := pkgs[0]
+
+	checkDefinition(t, fset, p, "closure.go", "\t^count++", p, "closure.go", "count := 0")
+	checkDefinition(t, fset, p, "closure.go", "return ^count\n\t\t\t}", p, "closure.go", "count := 0")
+	// The innermost declaration in scope wins over outer ones.
+	checkDefinition(t, fset, p, "closure.go", "return ^count\n\t\t}()", p, "closure.go", "count := 2")
+}
+
+func TestDefinitionGroupedDecls(t *testing.T) {
+	fset, pkgs := loadTestPackages(t, testSource{path: "grouped", files: map[string]string{"grouped.go": `package grouped
+
+import (
+	"fmt"
+	str "strings"
+)
+
+var (
+	a int
+	b string
+)
+
+const (
+	x = 1
+	y = "y"
+)
+
+func f() string {
+	return fmt.Sprint(a, x) + str.ToUpper(b+y)
+}
+`}})
+	p := pkgs[0]
+
+	for _, test := range []struct{ marker, decl string }{
+		{"\t^a int", "a int"},
+		{"\tb ^string", ""},
+		{"\t^b string", "b string"},
+		{"\t^x = 1", "x = 1"},
+		{"\t^y = \"y\"", "y = \"y\""},
+		{"(^a, x)", "a int"},
+		{"(a, ^x)", "x = 1"},
+		{"(^b+y)", "b string"},
+		{"(b+^y)", "y = \"y\""},
+	} {
+		if test.decl == "" {
+			// The type of a spec resolves to the universe type.
+			def, err := Definition(p, fset, testPos(t, fset, p, "grouped.go", test.marker))
+			if err != nil || def.Object != types.Universe.Lookup("string") {
+				t.Errorf("%s: got %v, %v, want the string type", test.marker, def, err)
+			}
+			continue
+		}
+		checkDefinition(t, fset, p, "grouped.go", test.marker, p, "grouped.go", test.decl)
+	}
+
+	for _, marker := range []string{"\t\"^fmt\"", "\t^str \"strings\"", "\tstr \"^strings\""} {
+		pos := testPos(t, fset, p, "grouped.go", marker)
+		path, _, _ := doEnclosingInterval(p, fset, pos, pos)
+		path, action := findInterestingNode(p, path)
+		if action != actionPackage {
+			t.Errorf("%s: classified as %v, want actionPackage", marker, action)
+			continue
+		}
+		if _, ok := path[0].(*ast.ImportSpec); !ok {
+			if _, ok := p.typesInfo.ObjectOf(path[0].(*ast.Ident)).(*types.PkgName); !ok {
+				t.Errorf("%s: got %T, want the import spec or package name", marker, path[0])
+			}
+		}
+	}
+
+	// The grouping keyword denotes none of the specs.
+	pos := testPos(t, fset, p, "grouped.go", "var (")
+	path, _, _ := doEnclosingInterval(p, fset, pos, pos)
+	if _, action := findInterestingNode(p, path); action != actionUnknown {
+		t.Errorf("var keyword of a group classified as %v, want actionUnknown", action)
+	}
+}
+
+func TestDefinitionTypeParameters(t *testing.T) {
+	fset, pkgs := loadTestPackages(t, testSource{path: "generic", files: map[string]string{"generic.go": `package generic
+
+type List[T any] struct{ items []T }
+
+type Pair[K comparable, V any] struct {
+	key K
+	val V
+}
+
+func F[T any](x T) T { return x }
+
+var (
+	l List[int]
+	p Pair[string, List[int]]
+)
+`}})
+	p := pkgs[0]
+
+	for _, test := range []struct{ marker, decl string }{
+		{"func F[^T any]", "T any](x"},
+		{"](x ^T)", "T any](x"},
+		{"(x T) ^T {", "T any](x"},
+		{"List[^T any]", "T any] struct"},
+		{"items []^T", "T any] struct"},
+		{"Pair[K comparable, ^V any]", "V any] struct"},
+		{"val ^V", "V any] struct"},
+	} {
+		def := checkDefinition(t, fset, p, "generic.go", test.marker, p, "generic.go", test.decl)
+		if _, ok := def.Object.Type().(*types.TypeParam); !ok {
+			t.Errorf("%s: got %v, want a type parameter", test.marker, def.Object)
+		}
+		pos := testPos(t, fset, p, "generic.go", test.marker)
+		path, _, _ := doEnclosingInterval(p, fset, pos, pos)
+		if _, action := findInterestingNode(p, path); action != actionType {
+			t.Errorf("%s: classified as %v, want actionType", test.marker, action)
+		}
+	}
+
+	for _, marker := range []string{"l ^List[int]", "l List^[int]", "p Pair^[string", "List[int]^]\n)"} {
+		pos := testPos(t, fset, p, "generic.go", marker)
+		path, _, _ := doEnclosingInterval(p, fset, pos, pos)
+		if _, action := findInterestingNode(p, path); action != actionType {
+			t.Errorf("%s: instantiation classified as %v, want actionType", marker, action)
+		}
+	}
+	checkDefinition(t, fset, p, "generic.go", "string, ^List[int]", p, "generic.go", "List[T any]")
+}
+
+func TestDefinitionReflectTypeOf(t *testing.T) {
+	fset, pkgs := loadTestPackages(t, testSource{path: "refl", files: map[string]string{"refl.go": `package refl
+
+import "reflect"
+
+type MyStruct struct{ Name string }
+
+var (
+	t1 = reflect.TypeOf(MyStruct{})
+	t2 = reflect.TypeOf(&MyStruct{Name: "x"})
+	t3 = reflect.TypeOf((*MyStruct)(nil))
+)
+`}})
+	p := pkgs[0]
+
+	for _, marker := range []string{"TypeOf(^MyStruct{})", "TypeOf(&^MyStruct{", "TypeOf((*^MyStruct)(nil))"} {
+		checkDefinition(t, fset, p, "refl.go", marker, p, "refl.go", "MyStruct struct")
+	}
+	checkDefinition(t, fset, p, "refl.go", "MyStruct{^Name:", p, "refl.go", "Name string")
+}
+
+func TestDefinitionAliasConversion(t *testing.T) {
+	fset, pkgs := loadTestPackages(t, testSource{path: "alias", files: map[string]string{"alias.go": `package alias
+
+type ID = int
+
+type Name string
+
+func convert(x int64) (ID, Name) {
+	return ID(x), Name("n")
+}
+`}})
+	p := pkgs[0]
+
+	def := checkDefinition(t, fset, p, "alias.go", "return ^ID(x)", p, "alias.go", "ID = int")
+	if def.Aliased == nil || def.Aliased.String() != "int" {
+		t.Errorf("ID: Aliased = %v, want int", def.Aliased)
+	}
+	def = checkDefinition(t, fset, p, "alias.go", "^Name(\"n\")", p, "alias.go", "Name string")
+	if def.Aliased != nil {
+		t.Errorf("Name: Aliased = %v, want nil for a defined type", def.Aliased)
+	}
+}
+
+func TestTypeDefinition(t *testing.T) {
+	fset, pkgs := loadTestPackages(t, testSource{path: "td", files: map[string]string{"td.go": `package td
+
+type Point struct{ X, Y int }
+
+var (
+	value  Point
+	ptr    = &value
+	list   []*Point
+	byName map[string]Point
+	count  int
+	err    error
+)
+`}})
+	p := pkgs[0]
+
+	ident := func(marker string) ast.Expr {
+		pos := testPos(t, fset, p, "td.go", marker)
+		path, _, _ := doEnclosingInterval(p, fset, pos, pos)
+		return path[0].(*ast.Ident)
+	}
+
+	for _, marker := range []string{"^value  Point", "^ptr", "^list", "^byName"} {
+		def, err := TypeDefinition(p, fset, ident(marker))
+		if err != nil {
+			t.Fatalf("%s: %v", marker, err)
+		}
+		if def == nil || def.Object.Name() != "Point" {
+			t.Errorf("%s: got type definition %v, want Point", marker, def)
+		} else if want := testPos(t, fset, p, "td.go", "^Point struct"); len(def.Path) == 0 || def.Path[0].Pos() != want {
+			t.Errorf("%s: declaration path does not start at Point", marker)
+		}
+	}
+	for _, marker := range []string{"^count", "^err"} {
+		if def, err := TypeDefinition(p, fset, ident(marker)); err != nil || def != nil {
+			t.Errorf("%s: got type definition %v, %v, want none", marker, def, err)
+		}
+	}
+}
+
+func TestDefinitionTransitiveImport(t *testing.T) {
+	fset, pkgs := loadTestPackages(t,
+		testSource{path: "c", files: map[string]string{"c.go": `package c
+
+type Config struct{ Verbose bool }
+`}},
+		testSource{path: "b", files: map[string]string{"b.go": `package b
+
+import "c"
+
+var Default = c.Config{}
+`}},
+		testSource{path: "a", files: map[string]string{"a.go": `package a
+
+import "b"
+
+var verbose = b.Default.Verbose
+`}},
+	)
+	c, a := pkgs[0], pkgs[2]
+
+	if a.GetImport("c") != nil {
+		t.Fatal("c is imported directly by a")
+	}
+	checkDefinition(t, fset, a, "a.go", "Default.^Verbose", c, "c.go", "Verbose bool")
+}
+
+func TestDefinitionTypeShadowedByValue(t *testing.T) {
+	fset, pkgs := loadTestPackages(t, testSource{path: "shadow", files: map[string]string{"shadow.go": `package shadow
+
+type T struct{ N int }
+
+var zero T
+
+func count(items []int) int {
+	T := len(items)
+	if T > 0 {
+		var v T2 = T2(T)
+		return int(v)
+	}
+	return T
+}
+
+type T2 int
+
+func make() T { return T{N: 1} }
+`}})
+	p := pkgs[0]
+
+	for _, marker := range []string{"var zero ^T", "func make() ^T", "return ^T{N"} {
+		def := checkDefinition(t, fset, p, "shadow.go", marker, p, "shadow.go", "type ^T struct")
+		if _, ok := def.Object.(*types.TypeName); !ok {
+			t.Errorf("%s: resolved to %T, want the type name", marker, def.Object)
+		}
+	}
+	for _, marker := range []string{"if ^T > 0", "T2(^T)", "return ^T\n"} {
+		def := checkDefinition(t, fset, p, "shadow.go", marker, p, "shadow.go", "^T := len")
+		if _, ok := def.Object.(*types.Var); !ok {
+			t.Errorf("%s: resolved to %T, want the local variable", marker, def.Object)
+		}
+	}
+}
+
+func TestDefinitionEmbeddedFieldType(t *testing.T) {
+	fset, pkgs := loadTestPackages(t, testSource{path: "embed", files: map[string]string{"embed.go": `package embed
+
+import "io"
+
+type Inner struct{ N int }
+
+type Named interface{ Name() string }
+
+type Outer struct {
+	Inner   ` + "`json:\"inner\"`" + `
+	*Config  ` + "`json:\"config\"`" + `
+	Named   ` + "`json:\"named\"`" + `
+	io.Reader
+}
+
+type Config struct{}
+`}})
+	p := pkgs[0]
+
+	for _, test := range []struct{ marker, declMarker string }{
+		{"\t^Inner  ", "^Inner struct"},
+		{"Inner  ^ `", "^Inner struct"},
+		{"\t*^Config", "^Config struct"},
+		{"*Config ^ `", "^Config struct"},
+		{"\t^Named  ", "^Named interface"},
+		{"Named  ^ `", "^Named interface"},
+	} {
+		pos := testPos(t, fset, p, "embed.go", test.marker)
+		path, _, _ := doEnclosingInterval(p, fset, pos, pos)
+		if _, action := findInterestingNode(p, path); action != actionType {
+			t.Errorf("%s: classified as %v, want actionType", test.marker, action)
+		}
+		checkDefinition(t, fset, p, "embed.go", test.marker, p, "embed.go", test.declMarker)
+	}
+
+	pos := testPos(t, fset, p, "embed.go", "io.^Reader")
+	def, err := Definition(p, fset, pos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if def.Object != p.types.Imports()[0].Scope().Lookup("Reader") {
+		t.Errorf("io.Reader resolved to %v, want the io.Reader type", def.Object)
+	}
+}
+
+func TestCommentLinkDefinition(t *testing.T) {
+	fset, pkgs := loadTestPackages(t, testSource{path: "conf", files: map[string]string{"conf.go": `package conf
+
+import (
+	"io"
+	"time"
+)
+
+const DefaultTimeout = 5 * time.Second
+
+type Mode int
+
+const ModeFast Mode = 1
+
+func (Mode) String() string { return "" }
+
+type Options struct {
+	// Timeout bounds each request, default: [DefaultTimeout].
+	Timeout time.Duration
+	Mode    Mode // default: [ModeFast], see [Mode.String]
+	Out     io.Writer // default: [io.Discard], not [missing] or [a b]
+}
+`}})
+	p := pkgs[0]
+
+	for _, test := range []struct{ marker, declMarker string }{
+		{"[^DefaultTimeout]", "^DefaultTimeout ="},
+		{"[DefaultTime^out]", "^DefaultTimeout ="},
+		{"[^ModeFast]", "^ModeFast Mode"},
+		{"[Mode.^String]", "^String() string"},
+	} {
+		def, err := CommentLinkDefinition(p, fset, testPos(t, fset, p, "conf.go", test.marker))
+		if err != nil {
+			t.Errorf("%s: %v", test.marker, err)
+			continue
+		}
+		if want := testPos(t, fset, p, "conf.go", test.declMarker); def.Object.Pos() != want || len(def.Path) == 0 || def.Path[0].Pos() != want {
+			t.Errorf("%s: resolved to %v at %s, want %s", test.marker, def.Object, fset.Position(def.Object.Pos()), fset.Position(want))
+		}
+	}
+
+	def, err := CommentLinkDefinition(p, fset, testPos(t, fset, p, "conf.go", "[io.^Discard]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if def.Object.Pkg().Path() != "io" || def.Object.Name() != "Discard" {
+		t.Errorf("[io.Discard] resolved to %v", def.Object)
+	}
+	for _, marker := range []string{"[^missing]", "[a ^b]", "de^fault: [io", "Timeout ^time.Duration"} {
+		if def, err := CommentLinkDefinition(p, fset, testPos(t, fset, p, "conf.go", marker)); err == nil {
+			t.Errorf("%s: resolved to %v, want an error", marker, def.Object)
+		}
+	}
+}
+
+func TestDefinitionSelectorDot(t *testing.T) {
+	fset, pkgs := loadTestPackages(t, testSource{path: "dot", files: map[string]string{"dot.go": `package dot
+
+import "fmt"
+
+type T struct{ Field int }
+
+func (T) Method() {}
+
+func f(t T) string {
+	t.Method()
+	return fmt.Sprint(t . Field)
+}
+`}})
+	p := pkgs[0]
+
+	for _, test := range []struct{ marker, declMarker string }{
+		{"t^.Method()", "^Method() {}"},
+		{"t ^. Field", "^Field int"},
+		{"t .^ Field", "^Field int"},
+	} {
+		checkDefinition(t, fset, p, "dot.go", test.marker, p, "dot.go", test.declMarker)
+	}
+
+	def, err := Definition(p, fset, testPos(t, fset, p, "dot.go", "fmt^.Sprint"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if def.Object.Pkg().Path() != "fmt" || def.Object.Name() != "Sprint" {
+		t.Errorf("fmt.Sprint resolved to %v, want fmt.Sprint", def.Object)
+	}
+}
+
+func TestDefinitionPromotedSelection(t *testing.T) {
+	fset, pkgs := loadTestPackages(t, testSource{path: "promo", files: map[string]string{"promo.go": `package promo
+
+type Base struct{ ID int }
+
+func (Base) Describe() string { return "" }
+
+type Middle struct{ Base }
+
+type Top struct{ *Middle }
+
+func f(x Top) (string, int) {
+	return x.Describe(), x.ID
+}
+`}})
+	p := pkgs[0]
+
+	for _, test := range []struct{ marker, declMarker string }{
+		{"x.^Describe()", "^Describe() string"},
+		{"x.^ID", "^ID int"},
+	} {
+		def := checkDefinition(t, fset, p, "promo.go", test.marker, p, "promo.go", test.declMarker)
+		if len(def.Promotion) != 2 {
+			t.Errorf("%s: got promotion path %v, want Middle and Base", test.marker, def.Promotion)
+		}
+	}
+}
+
+func TestDefinitionMethodValuesInSliceLiteral(t *testing.T) {
+	fset, pkgs := loadTestPackages(t, testSource{path: "handlers", files: map[string]string{"handlers.go": `package handlers
+
+type T struct{}
+
+func (T) A() {}
+
+func (*T) B() {}
+
+func register(t *T) []func() {
+	handlers := []func(){t.A, t.B}
+	return handlers
+}
+`}})
+	p := pkgs[0]
+
+	checkDefinition(t, fset, p, "handlers.go", "{t.^A,", p, "handlers.go", "^A() {}")
+	checkDefinition(t, fset, p, "handlers.go", "t.^B}", p, "handlers.go", "^B() {}")
+	checkDefinition(t, fset, p, "handlers.go", "{^t.A", p, "handlers.go", "register(^t *T)")
+}
+
+func TestVariantDefinition(t *testing.T) {
+	const lib = `package lib
+
+func Sum(xs ...int) int { return 0 }
+`
+	fset, pkgs := loadTestPackages(t,
+		testSource{path: "lib", files: map[string]string{"lib.go": lib}},
+		testSource{path: "lib", files: map[string]string{
+			"lib.go": lib,
+			"lib_test.go": `package lib
+
+func fixture() []int { return []int{1, 2} }
+
+func check() bool { return Sum(fixture()...) == 3 }
+`,
+		}},
+	)
+	main, test := pkgs[0], pkgs[1]
+
+	pos := testPos(t, fset, test, "lib_test.go", "Sum(^fixture()")
+	if _, err := Definition(main, fset, pos); err == nil {
+		t.Fatal("the main package resolves a position of its test file")
+	}
+	def, err := VariantDefinition(testCache{main, test}, main, fset, pos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := testPos(t, fset, test, "lib_test.go", "func ^fixture"); def.Object.Pos() != want || len(def.Path) == 0 {
+		t.Errorf("resolved to %v at %s, want fixture of the test variant", def.Object, fset.Position(def.Object.Pos()))
+	}
+
+	// Positions of the main package are resolved in it.
+	def, err = VariantDefinition(testCache{main, test}, main, fset, testPos(t, fset, main, "lib.go", "func ^Sum"))
+	if err != nil || def.Object != main.types.Scope().Lookup("Sum") {
+		t.Errorf("got %v, %v, want Sum of the main package", def, err)
+	}
+	if _, err := VariantDefinition(testCache{main}, main, fset, pos); err == nil {
+		t.Error("resolved a test file position without the test variant")
+	}
+}
+
+func TestDefinitionVariadicInterfaceArgs(t *testing.T) {
+	fset, pkgs := loadTestPackages(t, testSource{path: "logs", files: map[string]string{"logs.go": `package logs
+
+import "fmt"
+
+type Conn struct {
+	Addr string
+}
+
+func printAll(args ...interface{}) {}
+
+func report(c *Conn, count int, rest []interface{}) {
+	fmt.Println(c, count, c.Addr)
+	printAll(count, c.Addr)
+	printAll(rest...)
+}
+`}})
+	p := pkgs[0]
+
+	checkDefinition(t, fset, p, "logs.go", "Println(^c,", p, "logs.go", "report(^c *Conn")
+	checkDefinition(t, fset, p, "logs.go", "Println(c, ^count", p, "logs.go", "^count int")
+	checkDefinition(t, fset, p, "logs.go", "count, c.^Addr)\n\tprintAll", p, "logs.go", "^Addr string")
+	checkDefinition(t, fset, p, "logs.go", "printAll(^count", p, "logs.go", "^count int")
+	checkDefinition(t, fset, p, "logs.go", "printAll(^rest...)", p, "logs.go", "^rest []interface{}")
+	// The ellipsis spreads the last argument rather than denoting the call.
+	checkDefinition(t, fset, p, "logs.go", "printAll(rest^...)", p, "logs.go", "^rest []interface{}")
+	checkDefinition(t, fset, p, "logs.go", "printAll(rest..^.)", p, "logs.go", "^rest []interface{}")
+	if _, err := Definition(p, fset, testPos(t, fset, p, "logs.go", "printAll(rest...^)")); err == nil {
+		t.Errorf("closing parenthesis of a spread call resolved to an object")
+	}
+
+	// Each argument hovers with its own type, not the interface{} of the variadic parameter.
+	for _, test := range []struct{ marker, signature string }{
+		{"Println(^c,", "var c *Conn"},
+		{"Println(c, ^count", "var count int"},
+		{"printAll(count, c.^Addr", "field Addr string"},
+		{"printAll(^rest...)", "var rest []interface{}"},
+	} {
+		hover, err := Hover(p, fset, testPos(t, fset, p, "logs.go", test.marker))
+		if err != nil {
+			t.Errorf("%s: %v", test.marker, err)
+			continue
+		}
+		if hover.Signature != test.signature {
+			t.Errorf("%s: got %q, want %q", test.marker, hover.Signature, test.signature)
+		}
+	}
+}
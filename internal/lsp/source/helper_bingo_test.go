@@ -0,0 +1,200 @@
+package source
+
+import (
+	"context"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/packages"
+)
+
+// testPackage is a minimal Package built directly from source text.
+type testPackage struct {
+	id        string
+	filenames []string
+	contents  []string
+	syntax    []*ast.File
+	errors    []packages.Error
+	types     *types.Package
+	typesInfo *types.Info
+	imports   map[string]Package
+}
+
+func (p *testPackage) ID() string                  { return p.id }
+func (p *testPackage) PkgPath() string             { return p.types.Path() }
+func (p *testPackage) GetFilenames() []string      { return p.filenames }
+func (p *testPackage) GetSyntax() []*ast.File      { return p.syntax }
+func (p *testPackage) GetErrors() []packages.Error { return p.errors }
+func (p *testPackage) GetTypes() *types.Package    { return p.types }
+func (p *testPackage) GetTypesInfo() *types.Info   { return p.typesInfo }
+func (p *testPackage) GetTypesSizes() types.Sizes  { return types.SizesFor("gc", "amd64") }
+func (p *testPackage) IsIllTyped() bool            { return p.types == nil && p.typesInfo == nil }
+func (p *testPackage) GetDiagnostics() []Diagnostic {
+	return nil
+}
+func (p *testPackage) SetDiagnostics(diags []Diagnostic) {}
+func (p *testPackage) GetActionGraph(ctx context.Context, a *analysis.Analyzer) (*Action, error) {
+	return nil, nil
+}
+
+func (p *testPackage) GetImport(pkgPath string) Package {
+	if imp := p.imports[pkgPath]; imp != nil {
+		return imp
+	}
+	return nil
+}
+
+// testCache is an ICache over a fixed set of packages.
+type testCache []Package
+
+func (c testCache) Walk(walkFunc WalkFunc) {
+	for _, p := range c {
+		if walkFunc(p) {
+			return
+		}
+	}
+}
+
+// Delete is a no-op, as testCache is immutable.
+func (c testCache) Delete(pkgPath string) []string { return nil }
+
+// testSource describes a package to load: its import path and its files
+// keyed by file name. The files are named as if they lived in dir, which
+// defaults to a directory that does not exist on disk.
+type testSource struct {
+	path  string
+	dir   string
+	files map[string]string
+}
+
+// loadTestPackages parses and type-checks srcs in order, so a package may
+// import any package listed before it as well as the standard library.
+func loadTestPackages(t testing.TB, srcs ...testSource) (*token.FileSet, []*testPackage) {
+	t.Helper()
+
+	fset := token.NewFileSet()
+	loaded := make(map[string]*testPackage)
+	std := importer.Default()
+	var pkgs []*testPackage
+	for _, src := range srcs {
+		var names []string
+		for name := range src.files {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		p := &testPackage{
+			id:      src.path,
+			imports: make(map[string]Package),
+		}
+		dir := src.dir
+		if dir == "" {
+			dir = "/src/" + src.path
+		}
+		for _, name := range names {
+			filename := filepath.Join(dir, name)
+			f, err := parser.ParseFile(fset, filename, src.files[name], parser.ParseComments)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p.filenames = append(p.filenames, filename)
+			p.contents = append(p.contents, src.files[name])
+			p.syntax = append(p.syntax, f)
+		}
+
+		p.typesInfo = &types.Info{
+			Types:      make(map[ast.Expr]types.TypeAndValue),
+			Defs:       make(map[*ast.Ident]types.Object),
+			Uses:       make(map[*ast.Ident]types.Object),
+			Implicits:  make(map[ast.Node]types.Object),
+			Selections: make(map[*ast.SelectorExpr]*types.Selection),
+			Scopes:     make(map[ast.Node]*types.Scope),
+		}
+		cfg := &types.Config{
+			Importer: testImporter{std: std, loaded: loaded, imports: p.imports},
+			Error: func(err error) {
+				p.errors = append(p.errors, packages.Error{Msg: err.Error(), Kind: packages.TypeError})
+			},
+		}
+		p.types, _ = cfg.Check(src.path, fset, p.syntax, p.typesInfo)
+		loaded[src.path] = p
+		pkgs = append(pkgs, p)
+	}
+
+	return fset, pkgs
+}
+
+// testImporter resolves imports against previously loaded test packages,
+// including those under a vendor directory, falling back to the standard
+// library. Like go/packages, it records imports by the path written in the
+// import declaration.
+type testImporter struct {
+	std     types.Importer
+	loaded  map[string]*testPackage
+	imports map[string]Package
+}
+
+func (i testImporter) Import(path string) (*types.Package, error) {
+	if p, ok := i.loaded[path]; ok {
+		i.imports[path] = p
+		return p.types, nil
+	}
+	for loadedPath, p := range i.loaded {
+		if strings.HasSuffix(loadedPath, "/vendor/"+path) {
+			i.imports[path] = p
+			return p.types, nil
+		}
+	}
+	return i.std.Import(path)
+}
+
+// testPos returns the position of the first occurrence of marker in the
+// file of pkg named filename. The marker may contain a "^" to point at a
+// character inside it; otherwise the position is the start of the marker.
+func testPos(t *testing.T, fset *token.FileSet, pkg *testPackage, filename, marker string) token.Pos {
+	t.Helper()
+
+	offset := strings.Index(marker, "^")
+	if offset >= 0 {
+		marker = marker[:offset] + marker[offset+1:]
+	} else {
+		offset = 0
+	}
+	for i, f := range pkg.syntax {
+		if !strings.HasSuffix(pkg.filenames[i], "/"+filename) {
+			continue
+		}
+		idx := strings.Index(pkg.contents[i], marker)
+		if idx < 0 {
+			t.Fatalf("marker %q not found in %s", marker, filename)
+		}
+		return fset.File(f.Pos()).Pos(idx + offset)
+	}
+	t.Fatalf("file %s not found in %s", filename, pkg.id)
+	return token.NoPos
+}
+
+// testLocationText returns the source text covered by loc, which must lie in
+// one of pkgs.
+func testLocationText(t *testing.T, pkgs []*testPackage, loc Location) string {
+	t.Helper()
+
+	filename := loc.Span.URI().Filename()
+	for _, p := range pkgs {
+		for i, name := range p.filenames {
+			if name == filename {
+				return p.contents[i][loc.Span.Start().Offset():loc.Span.End().Offset()]
+			}
+		}
+	}
+	t.Fatalf("location %v is not in a test package", loc.Span)
+	return ""
+}
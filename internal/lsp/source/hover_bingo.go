@@ -0,0 +1,237 @@
+package source
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"go/types"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/internal/span"
+)
+
+// DeclSource returns the declaration of o rendered as formatted Go source.
+// Function and method declarations are rendered without their body, and
+// specs of grouped declarations are rendered on their own, such as
+// 'type T struct{...}' for T in 'type (...)'.
+func DeclSource(pkg Package, fset *token.FileSet, o types.Object) (string, error) {
+	if o.Pkg() == nil || !o.Pos().IsValid() {
+		return "", fmt.Errorf("no declaration for %s", o.Name())
+	}
+	path, _, err := getObjectPathNode(pkg, fset, o)
+	if err != nil {
+		return "", err
+	}
+
+	node, err := declNode(path)
+	if err != nil {
+		return "", fmt.Errorf("no declaration for %s: %v", o.Name(), err)
+	}
+	var b bytes.Buffer
+	if field, ok := node.(*ast.Field); ok {
+		// The printer does not render fields on their own.
+		for i, name := range field.Names {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(name.Name)
+		}
+		if len(field.Names) > 0 {
+			b.WriteString(" ")
+		}
+		node = field.Type
+	}
+	if err := format.Node(&b, fset, node); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// declNode returns the node to render for the declaration enclosing the
+// declaring identifier path[0].
+func declNode(path []ast.Node) (ast.Node, error) {
+	for i, n := range path {
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			decl := *n
+			decl.Doc = nil
+			decl.Body = nil
+			return &decl, nil
+		case *ast.TypeSpec, *ast.ValueSpec:
+			decl, ok := path[i+1].(*ast.GenDecl)
+			if !ok {
+				return nil, fmt.Errorf("%T outside declaration", n)
+			}
+			return &ast.GenDecl{Tok: decl.Tok, Specs: []ast.Spec{n.(ast.Spec)}}, nil
+		case *ast.Field, *ast.AssignStmt:
+			return n, nil
+		case *ast.FuncLit, *ast.BlockStmt, *ast.File:
+			return nil, fmt.Errorf("unsupported declaration in %T", n)
+		}
+	}
+	return nil, fmt.Errorf("no declaration node")
+}
+
+// HoverInfo is the hover content of an object.
+type HoverInfo struct {
+	// Signature is the object rendered by types.ObjectString, such as
+	// 'func F(x int) error', qualified relative to the hovered package.
+	Signature string
+	// Doc is the documentation comment of the declaration, which may be
+	// written on the enclosing grouped declaration rather than the spec.
+	Doc string
+	// PkgPath is the path of the package declaring the object, or "" for
+	// a predeclared object.
+	PkgPath string
+}
+
+// Hover returns the hover content of the object the syntax at pos in pkg
+// refers to.
+func Hover(pkg Package, fset *token.FileSet, pos token.Pos) (*HoverInfo, error) {
+	def, err := Definition(pkg, fset, pos)
+	if err != nil {
+		return nil, err
+	}
+	if def.Object == nil {
+		return nil, fmt.Errorf("no object at %s", fset.Position(pos))
+	}
+
+	info := &HoverInfo{
+		Signature: types.ObjectString(def.Object, types.RelativeTo(pkg.GetTypes())),
+		Doc:       PullComments(def.Path),
+	}
+	if def.Object.Pkg() != nil {
+		info.PkgPath = def.Object.Pkg().Path()
+	}
+	return info, nil
+}
+
+// PackageOverview describes a package as a whole, for the package clause.
+type PackageOverview struct {
+	Name string
+	Path string
+	// Doc is the package documentation, gathered from the package comments
+	// of all its files.
+	Doc   string
+	Files []string
+}
+
+// HoverPackageClause returns the overview of pkg if pos is on the package
+// keyword or package name of the package clause of one of its files.
+func HoverPackageClause(pkg Package, fset *token.FileSet, pos token.Pos) (*PackageOverview, error) {
+	var clause bool
+	for _, f := range pkg.GetSyntax() {
+		if f.Package <= pos && pos <= f.Name.End() {
+			clause = true
+			break
+		}
+	}
+	if !clause {
+		return nil, fmt.Errorf("%s is not in a package clause", fset.Position(pos))
+	}
+
+	var doc []string
+	for _, f := range pkg.GetSyntax() {
+		if text := f.Doc.Text(); text != "" {
+			doc = append(doc, text)
+		}
+	}
+	return &PackageOverview{
+		Name:  pkg.GetTypes().Name(),
+		Path:  pkg.GetTypes().Path(),
+		Doc:   strings.Join(doc, "\n"),
+		Files: pkg.GetFilenames(),
+	}, nil
+}
+
+// EmbedDirective is a //go:embed directive and the variable it initializes.
+type EmbedDirective struct {
+	Var *types.Var
+	// Patterns are the file patterns of the directive, unquoted.
+	Patterns []string
+	// Location is the location of the directive comment.
+	Location Location
+}
+
+// EmbedDirectives returns the //go:embed directives of the file uri, in
+// order, associated with the variable declared below each of them.
+func EmbedDirectives(pkg Package, fset *token.FileSet, uri span.URI) ([]EmbedDirective, error) {
+	file, err := lintFile(pkg, fset, uri)
+	if err != nil {
+		return nil, err
+	}
+
+	info := pkg.GetTypesInfo()
+	var directives []EmbedDirective
+	for _, decl := range file.Decls {
+		decl, ok := decl.(*ast.GenDecl)
+		if !ok || decl.Tok != token.VAR {
+			continue
+		}
+		for _, spec := range decl.Specs {
+			spec := spec.(*ast.ValueSpec)
+			doc := spec.Doc
+			if doc == nil && len(decl.Specs) == 1 {
+				doc = decl.Doc
+			}
+			if doc == nil || len(spec.Names) != 1 {
+				continue
+			}
+			v, ok := info.Defs[spec.Names[0]].(*types.Var)
+			if !ok {
+				continue
+			}
+			for _, c := range doc.List {
+				if !strings.HasPrefix(c.Text, "//go:embed ") {
+					continue
+				}
+				patterns, err := parseEmbedPatterns(strings.TrimPrefix(c.Text, "//go:embed "))
+				if err != nil {
+					return nil, fmt.Errorf("%s: %v", fset.Position(c.Pos()), err)
+				}
+				directives = append(directives, EmbedDirective{
+					Var:      v,
+					Patterns: patterns,
+					Location: toLocation(fset, c.Pos(), c.Text),
+				})
+			}
+		}
+	}
+
+	return directives, nil
+}
+
+// parseEmbedPatterns splits the arguments of a //go:embed directive, which
+// are separated by spaces and may be quoted.
+func parseEmbedPatterns(args string) ([]string, error) {
+	var patterns []string
+	for {
+		args = strings.TrimLeft(args, " \t")
+		if args == "" {
+			return patterns, nil
+		}
+		var pattern string
+		switch args[0] {
+		case '"', '`':
+			end := strings.IndexByte(args[1:], args[0])
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quoted pattern %s", args)
+			}
+			var err error
+			if pattern, err = strconv.Unquote(args[:end+2]); err != nil {
+				return nil, err
+			}
+			args = args[end+2:]
+		default:
+			end := strings.IndexAny(args, " \t")
+			if end < 0 {
+				end = len(args)
+			}
+			pattern, args = args[:end], args[end:]
+		}
+		patterns = append(patterns, pattern)
+	}
+}
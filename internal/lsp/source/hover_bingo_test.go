@@ -0,0 +1,172 @@
+package source
+
+import (
+	"go/types"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/internal/span"
+)
+
+func TestDeclSource(t *testing.T) {
+	fset, pkgs := loadTestPackages(t, testSource{path: "decl", files: map[string]string{"decl.go": `package decl
+
+// Point is a point.
+type (
+	Point struct {
+		X, Y int // coordinates
+	}
+	ID string
+)
+
+// Dist returns the distance.
+func (p Point) Dist(q Point) int {
+	dx := p.X - q.X
+	dy := p.Y - q.Y
+	return dx*dx + dy*dy
+}
+
+const Limit, Other = 10, 20
+`}})
+	p := pkgs[0]
+	scope := p.types.Scope()
+	point := scope.Lookup("Point")
+	dist, _, _ := types.LookupFieldOrMethod(point.Type(), false, p.types, "Dist")
+	field, _, _ := types.LookupFieldOrMethod(point.Type(), false, p.types, "Y")
+
+	for _, test := range []struct {
+		obj  types.Object
+		want string
+	}{
+		{point, "type Point struct {\n\tX, Y int // coordinates\n}"},
+		{scope.Lookup("ID"), "type ID string"},
+		{dist, "func (p Point) Dist(q Point) int"},
+		{field, "X, Y int"},
+		{scope.Lookup("Other"), "const Limit, Other = 10, 20"},
+	} {
+		got, err := DeclSource(p, fset, test.obj)
+		if err != nil {
+			t.Errorf("%s: %v", test.obj.Name(), err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.obj.Name(), got, test.want)
+		}
+	}
+
+	if _, err := DeclSource(p, fset, types.Universe.Lookup("int")); err == nil {
+		t.Error("DeclSource succeeded for a universe object")
+	}
+}
+
+func TestHoverPackageClause(t *testing.T) {
+	fset, pkgs := loadTestPackages(t, testSource{path: "example.com/geo", files: map[string]string{
+		"geo.go": `// Package geo computes distances.
+package geo
+
+func Dist() {}
+`,
+		"point.go": `package geo
+
+type Point struct{}
+`,
+	}})
+	p := pkgs[0]
+
+	for _, marker := range []string{"package geo\n\nfunc", "package ^geo\n\nfunc", "package ^geo\n\ntype"} {
+		file := "geo.go"
+		if strings.HasSuffix(marker, "type") {
+			file = "point.go"
+		}
+		o, err := HoverPackageClause(p, fset, testPos(t, fset, p, file, marker))
+		if err != nil {
+			t.Fatalf("%q: %v", marker, err)
+		}
+		if o.Name != "geo" || o.Path != "example.com/geo" || o.Doc != "Package geo computes distances.\n" || len(o.Files) != 2 {
+			t.Errorf("%q: got %+v", marker, o)
+		}
+	}
+
+	if _, err := HoverPackageClause(p, fset, testPos(t, fset, p, "geo.go", "Dist")); err == nil {
+		t.Error("HoverPackageClause succeeded outside the package clause")
+	}
+}
+
+func TestEmbedDirectives(t *testing.T) {
+	fset, pkgs := loadTestPackages(t, testSource{path: "assets", files: map[string]string{"assets.go": "package assets\n\n" +
+		"import \"embed\"\n\n" +
+		"//go:embed version.txt\n" +
+		"var version []byte\n\n" +
+		"// Static holds the web assets.\n" +
+		"//\n" +
+		"//go:embed static/*.css \"static/my file.js\"\n" +
+		"//go:embed `templates`\n" +
+		"var Static embed.FS\n\n" +
+		"var plain string\n",
+	}})
+	p := pkgs[0]
+
+	directives, err := EmbedDirectives(p, fset, span.FileURI(p.filenames[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, d := range directives {
+		got = append(got, d.Var.Name()+": "+strings.Join(d.Patterns, "|"))
+		if text := testLocationText(t, pkgs, d.Location); !strings.HasPrefix(text, "//go:embed ") {
+			t.Errorf("%s: location has text %q", d.Var.Name(), text)
+		}
+	}
+	want := []string{
+		"version: version.txt",
+		"Static: static/*.css|static/my file.js",
+		"Static: templates",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got directives %q, want %q", got, want)
+	}
+}
+
+func TestHover(t *testing.T) {
+	fset, pkgs := loadTestPackages(t,
+		testSource{path: "example.com/units", files: map[string]string{"units.go": `package units
+
+// Size units.
+const (
+	KB = 1 << (10 * (iota + 1))
+	MB
+)
+
+// Grow doubles size.
+func Grow(size int) int { return 2 * size }
+`}},
+		testSource{path: "app", files: map[string]string{"app.go": `package app
+
+import "example.com/units"
+
+func run() int {
+	limit := units.Grow(units.MB)
+	return limit
+}
+`}},
+	)
+	p := pkgs[1]
+
+	for _, test := range []struct {
+		marker                  string
+		signature, doc, pkgPath string
+	}{
+		{"units.^Grow(", "func example.com/units.Grow(size int) int", "Grow doubles size.\n", "example.com/units"},
+		{"units.^MB", "const example.com/units.MB untyped int", "Size units.\n", "example.com/units"},
+		{"return ^limit", "var limit int", "", "app"},
+	} {
+		hover, err := Hover(p, fset, testPos(t, fset, p, "app.go", test.marker))
+		if err != nil {
+			t.Errorf("%s: %v", test.marker, err)
+			continue
+		}
+		if hover.Signature != test.signature || hover.Doc != test.doc || hover.PkgPath != test.pkgPath {
+			t.Errorf("%s: got %+v, want {%s %q %s}", test.marker, hover, test.signature, test.doc, test.pkgPath)
+		}
+	}
+}
@@ -9,7 +9,6 @@ import (
 	"go/types"
 	"sort"
 
-	"golang.org/x/tools/go/ast/astutil"
 	"golang.org/x/tools/go/types/typeutil"
 )
 
@@ -20,7 +19,10 @@ func Implementation(ctx context.Context, search SearchFunc, f GoFile, pos token.
 		return nil, fmt.Errorf("package for %s is ill typed", f.URI())
 	}
 
-	path, _ := astutil.PathEnclosingInterval(file, pos, pos)
+	path, _, err := pathEnclosingIntervalLimited(file, pos, pos)
+	if err != nil {
+		return nil, err
+	}
 	if path == nil {
 		return nil, fmt.Errorf("cannot find node enclosing position")
 	}
@@ -0,0 +1,1094 @@
+package source
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/internal/span"
+)
+
+// lintFile returns the syntax of the file of pkg identified by uri, which
+// may name the file through a symbolic link.
+func lintFile(pkg Package, fset *token.FileSet, uri span.URI) (*ast.File, error) {
+	if pkg == nil || pkg.IsIllTyped() {
+		return nil, fmt.Errorf("package for %s is ill typed", uri)
+	}
+
+	filename := uri.Filename()
+	if f := pkgFile(pkg, fset, filename); f != nil {
+		return f, nil
+	}
+	// The go command reports the files of a package with symbolic links
+	// resolved, while the editor may open them through a link.
+	if real, err := filepath.EvalSymlinks(filename); err == nil && real != filename {
+		if f := pkgFile(pkg, fset, real); f != nil {
+			return f, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no file %s in package %s", uri, pkg.PkgPath())
+}
+
+// pkgFile returns the syntax of the file of pkg named filename, or nil.
+func pkgFile(pkg Package, fset *token.FileSet, filename string) *ast.File {
+	for _, f := range pkg.GetSyntax() {
+		if tok := fset.File(f.Pos()); tok != nil && tok.Name() == filename {
+			return f
+		}
+	}
+	return nil
+}
+
+// lintDiagnostic returns a warning spanning node.
+func lintDiagnostic(fset *token.FileSet, node ast.Node, source, format string, args ...interface{}) Diagnostic {
+	spn, _ := nodeSpan(node, fset)
+	return Diagnostic{
+		Span:     spn,
+		Message:  fmt.Sprintf(format, args...),
+		Source:   source,
+		Severity: SeverityWarning,
+	}
+}
+
+// LockCopy reports values of types containing a sync.Mutex or sync.RWMutex
+// in the file uri that are copied by assignment, passed by value or
+// returned by value.
+func LockCopy(pkg Package, fset *token.FileSet, uri span.URI) ([]Diagnostic, error) {
+	file, err := lintFile(pkg, fset, uri)
+	if err != nil {
+		return nil, err
+	}
+
+	info := pkg.GetTypesInfo()
+	var diags []Diagnostic
+	checkCopy := func(x ast.Expr, what string) {
+		if !copiesValue(x) {
+			return
+		}
+		if lock := lockPath(info.TypeOf(x)); lock != "" {
+			diags = append(diags, lintDiagnostic(fset, x, "lockcopy", "%s copies lock value: %s", what, lock))
+		}
+	}
+	checkParams := func(fields *ast.FieldList, what string) {
+		if fields == nil {
+			return
+		}
+		for _, field := range fields.List {
+			if lock := lockPath(info.TypeOf(field.Type)); lock != "" {
+				diags = append(diags, lintDiagnostic(fset, field.Type, "lockcopy", "%s passes lock by value: %s", what, lock))
+			}
+		}
+	}
+
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			for _, x := range n.Rhs {
+				checkCopy(x, "assignment")
+			}
+		case *ast.ValueSpec:
+			for _, x := range n.Values {
+				checkCopy(x, "variable declaration")
+			}
+		case *ast.ReturnStmt:
+			for _, x := range n.Results {
+				checkCopy(x, "return")
+			}
+		case *ast.CallExpr:
+			for _, x := range n.Args {
+				checkCopy(x, "call")
+			}
+		case *ast.FuncDecl:
+			checkParams(n.Recv, n.Name.Name)
+			checkParams(n.Type.Params, n.Name.Name)
+		case *ast.FuncLit:
+			checkParams(n.Type.Params, "func literal")
+		}
+		return true
+	})
+
+	return diags, nil
+}
+
+// copiesValue reports whether evaluating x copies an existing variable,
+// as opposed to producing a fresh value.
+func copiesValue(x ast.Expr) bool {
+	switch x := x.(type) {
+	case *ast.ParenExpr:
+		return copiesValue(x.X)
+	case *ast.Ident, *ast.SelectorExpr, *ast.IndexExpr, *ast.StarExpr:
+		return true
+	}
+	return false
+}
+
+// lockPath returns the path to a sync.Mutex or sync.RWMutex held by value
+// in typ, such as "T contains sync.Mutex", or "" if there is none.
+func lockPath(typ types.Type) string {
+	return findLock(typ, make(map[types.Type]bool))
+}
+
+func findLock(typ types.Type, seen map[types.Type]bool) string {
+	if typ == nil || seen[typ] {
+		return ""
+	}
+	seen[typ] = true
+
+	if named, ok := typ.(*types.Named); ok {
+		obj := named.Obj()
+		if obj.Pkg() != nil && obj.Pkg().Path() == "sync" && (obj.Name() == "Mutex" || obj.Name() == "RWMutex") {
+			return "sync." + obj.Name()
+		}
+	}
+
+	switch u := typ.Underlying().(type) {
+	case *types.Struct:
+		for i := 0; i < u.NumFields(); i++ {
+			if lock := findLock(u.Field(i).Type(), seen); lock != "" {
+				return typ.String() + " contains " + lock
+			}
+		}
+	case *types.Array:
+		if lock := findLock(u.Elem(), seen); lock != "" {
+			return typ.String() + " contains " + lock
+		}
+	}
+
+	return ""
+}
+
+// FileUnusedImports reports the imports of the file uri that are not used
+// in that file, regardless of their use in other files of the package.
+func FileUnusedImports(pkg Package, fset *token.FileSet, uri span.URI) ([]Diagnostic, error) {
+	file, err := lintFile(pkg, fset, uri)
+	if err != nil {
+		return nil, err
+	}
+
+	info := pkg.GetTypesInfo()
+	imports := make(map[*ast.ImportSpec]*types.PkgName)
+	for _, spec := range file.Imports {
+		var obj types.Object
+		if spec.Name != nil {
+			obj = info.Defs[spec.Name]
+		} else {
+			obj = info.Implicits[spec]
+		}
+		if pkgName, ok := obj.(*types.PkgName); ok {
+			imports[spec] = pkgName
+		}
+	}
+
+	used := make(map[*types.PkgName]bool)
+	ast.Inspect(file, func(n ast.Node) bool {
+		id, ok := n.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		obj := info.Uses[id]
+		if pkgName, ok := obj.(*types.PkgName); ok {
+			used[pkgName] = true
+			return true
+		}
+		// Members of dot-imported packages are used unqualified.
+		if obj != nil && obj.Pkg() != nil && obj.Pkg() != pkg.GetTypes() {
+			for spec, pkgName := range imports {
+				if spec.Name != nil && spec.Name.Name == "." && pkgName.Imported() == obj.Pkg() {
+					used[pkgName] = true
+				}
+			}
+		}
+		return true
+	})
+
+	var diags []Diagnostic
+	for _, spec := range file.Imports {
+		pkgName, ok := imports[spec]
+		if !ok || used[pkgName] || pkgName.Name() == "_" {
+			continue
+		}
+		diags = append(diags, lintDiagnostic(fset, spec, "unusedimport", "%s imported but not used in this file", spec.Path.Value))
+	}
+
+	return diags, nil
+}
+
+// ContextFirstParam reports functions in the file uri that take a
+// context.Context parameter anywhere but in first position.
+func ContextFirstParam(pkg Package, fset *token.FileSet, uri span.URI) ([]Diagnostic, error) {
+	file, err := lintFile(pkg, fset, uri)
+	if err != nil {
+		return nil, err
+	}
+
+	info := pkg.GetTypesInfo()
+	var diags []Diagnostic
+	check := func(params *ast.FieldList, name string) {
+		i := 0
+		for _, field := range params.List {
+			if i > 0 && isContextType(info.TypeOf(field.Type)) {
+				diags = append(diags, lintDiagnostic(fset, field, "contextfirst", "%s: context.Context should be the first parameter", name))
+			}
+			if n := len(field.Names); n > 0 {
+				i += n
+			} else {
+				i++
+			}
+		}
+	}
+
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			check(n.Type.Params, n.Name.Name)
+		case *ast.FuncLit:
+			check(n.Type.Params, "func literal")
+		}
+		return true
+	})
+
+	return diags, nil
+}
+
+func isContextType(typ types.Type) bool {
+	named, ok := typ.(*types.Named)
+	if !ok {
+		return false
+	}
+	obj := named.Obj()
+	return obj.Pkg() != nil && obj.Pkg().Path() == "context" && obj.Name() == "Context"
+}
+
+// StringConcatInLoop reports string variables in the file uri that are
+// built by repeated concatenation in a loop, as in 's += x' or 's = s + x',
+// which is quadratic; a strings.Builder should be used instead.
+func StringConcatInLoop(pkg Package, fset *token.FileSet, uri span.URI) ([]Diagnostic, error) {
+	file, err := lintFile(pkg, fset, uri)
+	if err != nil {
+		return nil, err
+	}
+
+	info := pkg.GetTypesInfo()
+	var diags []Diagnostic
+	reported := make(map[*ast.AssignStmt]bool)
+	checkLoop := func(loop ast.Node, body *ast.BlockStmt) {
+		ast.Inspect(body, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.FuncLit:
+				// The body of a function literal does not run per iteration.
+				return false
+			case *ast.AssignStmt:
+				if len(n.Lhs) != 1 || len(n.Rhs) != 1 || reported[n] {
+					return true
+				}
+				id, ok := n.Lhs[0].(*ast.Ident)
+				if !ok {
+					return true
+				}
+				v, ok := info.ObjectOf(id).(*types.Var)
+				if !ok || !isString(v.Type()) {
+					return true
+				}
+				// A variable declared in the loop starts afresh each iteration.
+				if loop.Pos() <= v.Pos() && v.Pos() < loop.End() {
+					return true
+				}
+				if n.Tok == token.ADD_ASSIGN || n.Tok == token.ASSIGN && isConcatOf(info, n.Rhs[0], v) {
+					reported[n] = true
+					diags = append(diags, lintDiagnostic(fset, n, "stringconcat", "%s is concatenated in a loop; consider using strings.Builder", id.Name))
+				}
+			}
+			return true
+		})
+	}
+
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.ForStmt:
+			checkLoop(n, n.Body)
+		case *ast.RangeStmt:
+			checkLoop(n, n.Body)
+		}
+		return true
+	})
+
+	return diags, nil
+}
+
+func isString(typ types.Type) bool {
+	b, ok := typ.Underlying().(*types.Basic)
+	return ok && b.Info()&types.IsString != 0
+}
+
+// isConcatOf reports whether x is a concatenation whose leftmost operand
+// is the variable v, as in 'v + a + b'.
+func isConcatOf(info *types.Info, x ast.Expr, v *types.Var) bool {
+	for {
+		switch e := x.(type) {
+		case *ast.ParenExpr:
+			x = e.X
+		case *ast.BinaryExpr:
+			if e.Op != token.ADD {
+				return false
+			}
+			x = e.X
+		case *ast.Ident:
+			return info.Uses[e] == v
+		default:
+			return false
+		}
+	}
+}
+
+// RedundantConversions reports conversions T(x) in the file uri where x
+// already has type T. Conversions of untyped constants, which give them a
+// type, are not redundant.
+func RedundantConversions(pkg Package, fset *token.FileSet, uri span.URI) ([]Diagnostic, error) {
+	file, err := lintFile(pkg, fset, uri)
+	if err != nil {
+		return nil, err
+	}
+
+	info := pkg.GetTypesInfo()
+	var diags []Diagnostic
+	ast.Inspect(file, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || len(call.Args) != 1 || call.Ellipsis.IsValid() {
+			return true
+		}
+		if tv, ok := info.Types[call.Fun]; !ok || !tv.IsType() {
+			return true
+		}
+		from, to := info.TypeOf(call.Args[0]), info.TypeOf(call.Fun)
+		if from == nil || to == nil {
+			return true
+		}
+		if types.Identical(from, to) && !isUntypedConst(info, call.Args[0]) {
+			diags = append(diags, lintDiagnostic(fset, call, "redundantconv", "redundant conversion to %s", types.TypeString(to, types.RelativeTo(pkg.GetTypes()))))
+		}
+		return true
+	})
+
+	return diags, nil
+}
+
+// isUntypedConst reports whether x is an untyped constant expression. The
+// type checker records such an operand with the type it is converted to, so
+// untypedness is recovered from the constants and literals it is made of.
+func isUntypedConst(info *types.Info, x ast.Expr) bool {
+	if tv, ok := info.Types[x]; !ok || tv.Value == nil {
+		return false
+	}
+	switch x := x.(type) {
+	case *ast.BasicLit:
+		return true
+	case *ast.Ident:
+		if c, ok := info.Uses[x].(*types.Const); ok {
+			b, ok := c.Type().(*types.Basic)
+			return ok && b.Info()&types.IsUntyped != 0
+		}
+	case *ast.ParenExpr:
+		return isUntypedConst(info, x.X)
+	case *ast.UnaryExpr:
+		return isUntypedConst(info, x.X)
+	case *ast.BinaryExpr:
+		if x.Op == token.SHL || x.Op == token.SHR {
+			return isUntypedConst(info, x.X)
+		}
+		return isUntypedConst(info, x.X) && isUntypedConst(info, x.Y)
+	}
+	return false
+}
+
+// PrintfCheck reports calls in the file uri to printf-like functions whose
+// constant format string does not match their arguments, either in number
+// or in type. A function is printf-like if its final parameters are a
+// format string and a variadic ...interface{}, like fmt.Printf.
+func PrintfCheck(pkg Package, fset *token.FileSet, uri span.URI) ([]Diagnostic, error) {
+	file, err := lintFile(pkg, fset, uri)
+	if err != nil {
+		return nil, err
+	}
+
+	info := pkg.GetTypesInfo()
+	var diags []Diagnostic
+	ast.Inspect(file, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		idx := printfFormatIndex(info, call)
+		if idx < 0 || idx >= len(call.Args) {
+			return true
+		}
+		tv := info.Types[call.Args[idx]]
+		if tv.Value == nil || tv.Value.Kind() != constant.String {
+			return true
+		}
+		name := types.ExprString(call.Fun)
+		verbs, ok := parsePrintfVerbs(constant.StringVal(tv.Value))
+		if !ok {
+			return true
+		}
+
+		args := call.Args[idx+1:]
+		for i, verb := range verbs {
+			if i >= len(args) {
+				if !call.Ellipsis.IsValid() {
+					diags = append(diags, lintDiagnostic(fset, call, "printf", "%s format %%%c reads arg #%d, but call has %d args", name, verb, i+1, len(args)))
+				}
+				return true
+			}
+			if typ := info.TypeOf(args[i]); typ != nil && !printfArgMatches(verb, typ) {
+				diags = append(diags, lintDiagnostic(fset, args[i], "printf", "%s format %%%c has arg %s of wrong type %s", name, verb, types.ExprString(args[i]), typ))
+			}
+		}
+		if len(args) > len(verbs) && !call.Ellipsis.IsValid() {
+			diags = append(diags, lintDiagnostic(fset, call, "printf", "%s call needs %d args but has %d args", name, len(verbs), len(args)))
+		}
+		return true
+	})
+
+	return diags, nil
+}
+
+// printfFormatIndex returns the index of the format argument of call if it
+// calls a printf-like function, or -1.
+func printfFormatIndex(info *types.Info, call *ast.CallExpr) int {
+	sig, ok := info.TypeOf(call.Fun).(*types.Signature)
+	if !ok || !sig.Variadic() || sig.Params().Len() < 2 {
+		return -1
+	}
+	params := sig.Params()
+	// The signature recorded for append(b, s...) with a string s is
+	// variadic, but its last parameter is the string, not a slice.
+	last, ok := params.At(params.Len() - 1).Type().(*types.Slice)
+	if !ok {
+		return -1
+	}
+	if iface, ok := last.Elem().Underlying().(*types.Interface); !ok || iface.NumMethods() != 0 {
+		return -1
+	}
+	format := params.At(params.Len() - 2)
+	if format.Name() != "format" || !isString(format.Type()) {
+		return -1
+	}
+	return params.Len() - 2
+}
+
+// parsePrintfVerbs returns the verbs of format that consume an argument,
+// with '*' standing for the int consumed by a '*' width or precision.
+// It reports false for formats with explicit argument indexes, which are
+// not checked.
+func parsePrintfVerbs(format string) ([]rune, bool) {
+	var verbs []rune
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+		i++
+		for i < len(format) && strings.IndexByte("+-# 0", format[i]) >= 0 {
+			i++
+		}
+		for i < len(format) && (format[i] == '*' || format[i] == '.' || '0' <= format[i] && format[i] <= '9') {
+			if format[i] == '*' {
+				verbs = append(verbs, '*')
+			}
+			i++
+		}
+		if i >= len(format) {
+			break
+		}
+		if format[i] == '[' {
+			return nil, false
+		}
+		verb, size := utf8.DecodeRuneInString(format[i:])
+		i += size - 1
+		if verb != '%' {
+			verbs = append(verbs, verb)
+		}
+	}
+	return verbs, true
+}
+
+// printfArgMatches reports whether an argument of type typ may be formatted
+// by verb. Verbs without specific requirements, and arguments of interface
+// or fmt.Formatter type, always match. Like vet, fmt applies the verb to
+// the elements of slices, arrays and maps, to the fields of structs, and to
+// what a pointer to one of these points to.
+func printfArgMatches(verb rune, typ types.Type) bool {
+	return printfArgMatchesIn(verb, typ, map[types.Type]bool{}, true)
+}
+
+// printfArgMatchesIn is printfArgMatches, where inProgress holds the
+// composite types being checked so that recursive types terminate, and top
+// reports whether typ is the argument itself rather than an element of it.
+func printfArgMatchesIn(verb rune, typ types.Type, inProgress map[types.Type]bool, top bool) bool {
+	if types.IsInterface(typ) && verb != 'w' || hasMethod(typ, "Format") {
+		return true
+	}
+	if verb != '*' && verb != 'w' {
+		if inProgress[typ] {
+			return true
+		}
+		inProgress[typ] = true
+		defer delete(inProgress, typ)
+
+		switch u := typ.Underlying().(type) {
+		case *types.Slice:
+			if elem, ok := u.Elem().(*types.Basic); ok && elem.Kind() == types.Byte && verb == 's' {
+				return true
+			}
+			return printfArgMatchesIn(verb, u.Elem(), inProgress, false)
+		case *types.Array:
+			return printfArgMatchesIn(verb, u.Elem(), inProgress, false)
+		case *types.Map:
+			return printfArgMatchesIn(verb, u.Key(), inProgress, false) && printfArgMatchesIn(verb, u.Elem(), inProgress, false)
+		case *types.Struct:
+			for i := 0; i < u.NumFields(); i++ {
+				if !printfArgMatchesIn(verb, u.Field(i).Type(), inProgress, false) {
+					return false
+				}
+			}
+			return true
+		case *types.Pointer:
+			// fmt prints &{...} for a pointer argument to a composite
+			// value, and the address otherwise.
+			if top {
+				switch u.Elem().Underlying().(type) {
+				case *types.Struct, *types.Slice, *types.Array, *types.Map:
+					return printfArgMatchesIn(verb, u.Elem(), inProgress, false)
+				}
+			}
+			if verb == 'd' {
+				return true
+			}
+		}
+	}
+	b, _ := typ.Underlying().(*types.Basic)
+	is := func(flag types.BasicInfo) bool { return b != nil && b.Info()&flag != 0 }
+	switch verb {
+	case '*', 'd':
+		return is(types.IsInteger)
+	case 't':
+		return is(types.IsBoolean)
+	case 'e', 'E', 'f', 'F', 'g', 'G':
+		return is(types.IsFloat | types.IsComplex)
+	case 's':
+		return is(types.IsString) || hasMethod(typ, "String") || hasMethod(typ, "Error")
+	case 'w':
+		return types.Implements(typ, types.Universe.Lookup("error").Type().Underlying().(*types.Interface))
+	}
+	return true
+}
+
+func hasMethod(typ types.Type, name string) bool {
+	obj, _, _ := types.LookupFieldOrMethod(typ, true, nil, name)
+	_, ok := obj.(*types.Func)
+	return ok
+}
+
+// EmptyInterfaceUsage reports parameters, results and struct fields in the
+// file uri declared as interface{} or any, where a type parameter or a more
+// specific type may be preferable. Type assertions and constraints are not
+// reported.
+func EmptyInterfaceUsage(pkg Package, fset *token.FileSet, uri span.URI) ([]Diagnostic, error) {
+	file, err := lintFile(pkg, fset, uri)
+	if err != nil {
+		return nil, err
+	}
+
+	info := pkg.GetTypesInfo()
+	var diags []Diagnostic
+	check := func(fields *ast.FieldList, what string) {
+		if fields == nil {
+			return
+		}
+		for _, field := range fields.List {
+			typ := field.Type
+			if ellipsis, ok := typ.(*ast.Ellipsis); ok {
+				typ = ellipsis.Elt
+			}
+			if isEmptyInterfaceExpr(info, typ) {
+				diags = append(diags, lintDiagnostic(fset, typ, "emptyinterface", "%s of type %s; consider a type parameter or a more specific type", what, types.ExprString(typ)))
+			}
+		}
+	}
+
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncType:
+			check(n.Params, "parameter")
+			check(n.Results, "result")
+		case *ast.StructType:
+			check(n.Fields, "field")
+		}
+		return true
+	})
+
+	return diags, nil
+}
+
+// isEmptyInterfaceExpr reports whether x is the literal interface{} or the
+// predeclared any.
+func isEmptyInterfaceExpr(info *types.Info, x ast.Expr) bool {
+	switch x := x.(type) {
+	case *ast.InterfaceType:
+		return len(x.Methods.List) == 0
+	case *ast.Ident:
+		return x.Name == "any" && info.Uses[x] == types.Universe.Lookup("any")
+	}
+	return false
+}
+
+// BuiltinShadow reports declarations in the file uri that shadow a
+// predeclared identifier, such as a variable named len or a type named
+// error. Fields and methods, which are always selected, are not reported.
+func BuiltinShadow(pkg Package, fset *token.FileSet, uri span.URI) ([]Diagnostic, error) {
+	file, err := lintFile(pkg, fset, uri)
+	if err != nil {
+		return nil, err
+	}
+
+	info := pkg.GetTypesInfo()
+	var diags []Diagnostic
+	ast.Inspect(file, func(n ast.Node) bool {
+		id, ok := n.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		obj := info.Defs[id]
+		if obj == nil {
+			return true
+		}
+		switch obj := obj.(type) {
+		case *types.Var:
+			if obj.IsField() {
+				return true
+			}
+		case *types.Func:
+			if obj.Type().(*types.Signature).Recv() != nil {
+				return true
+			}
+		}
+		var kind string
+		switch types.Universe.Lookup(id.Name).(type) {
+		case *types.Builtin:
+			kind = "function"
+		case *types.TypeName:
+			kind = "type"
+		case *types.Const:
+			kind = "constant"
+		case *types.Nil:
+			kind = "identifier"
+		default:
+			return true
+		}
+		diags = append(diags, lintDiagnostic(fset, id, "builtinshadow", "%s shadows the predeclared %s %s", id.Name, kind, id.Name))
+		return true
+	})
+
+	return diags, nil
+}
+
+// ReturnConcreteHint reports interface results of functions in the file uri
+// for which every return statement returns a value of the same concrete
+// type, suggesting to return that type instead. Error results, which are
+// conventionally interfaces, are not reported.
+func ReturnConcreteHint(pkg Package, fset *token.FileSet, uri span.URI) ([]Diagnostic, error) {
+	file, err := lintFile(pkg, fset, uri)
+	if err != nil {
+		return nil, err
+	}
+
+	info := pkg.GetTypesInfo()
+	var diags []Diagnostic
+	ast.Inspect(file, func(n ast.Node) bool {
+		fn, ok := n.(*ast.FuncDecl)
+		if !ok || fn.Body == nil {
+			return true
+		}
+		obj, ok := info.Defs[fn.Name].(*types.Func)
+		if !ok {
+			return true
+		}
+		sig := obj.Type().(*types.Signature)
+		results := sig.Results()
+		for i := 0; i < results.Len(); i++ {
+			typ := results.At(i).Type()
+			if !types.IsInterface(typ) || isErrorType(typ) {
+				continue
+			}
+			if concrete := soleReturnedType(info, fn.Body, i, results.Len()); concrete != nil {
+				q := types.RelativeTo(pkg.GetTypes())
+				diags = append(diags, lintDiagnostic(fset, resultTypeExpr(fn.Type.Results, i), "returnconcrete",
+					"%s always returns %s as %s; consider returning the concrete type", fn.Name.Name, types.TypeString(concrete, q), types.TypeString(typ, q)))
+			}
+		}
+		return true
+	})
+
+	return diags, nil
+}
+
+// isInvalidType reports whether typ, or what it points to, is the invalid
+// type of an expression that does not type-check.
+func isInvalidType(typ types.Type) bool {
+	if ptr, ok := typ.(*types.Pointer); ok {
+		typ = ptr.Elem()
+	}
+	return typ == types.Typ[types.Invalid]
+}
+
+func isErrorType(typ types.Type) bool {
+	return types.Identical(typ, types.Universe.Lookup("error").Type())
+}
+
+// soleReturnedType returns the concrete type of every non-nil value
+// returned as result i of n results by the return statements of body, or
+// nil if there are several such types, an interface among them, or a bare
+// return.
+func soleReturnedType(info *types.Info, body *ast.BlockStmt, i, n int) types.Type {
+	var sole types.Type
+	ok := true
+	ast.Inspect(body, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.ReturnStmt:
+			var typ types.Type
+			switch len(node.Results) {
+			case 0:
+				ok = false
+			case n:
+				typ = info.TypeOf(node.Results[i])
+			case 1:
+				if tuple, isTuple := info.TypeOf(node.Results[0]).(*types.Tuple); isTuple && tuple.Len() == n {
+					typ = tuple.At(i).Type()
+				}
+			}
+			if b, isBasic := typ.(*types.Basic); isBasic && b.Kind() == types.UntypedNil {
+				return true
+			}
+			switch {
+			case typ == nil || types.IsInterface(typ) || isInvalidType(typ):
+				ok = false
+			case sole == nil:
+				sole = typ
+			case !types.Identical(sole, typ):
+				ok = false
+			}
+		}
+		return ok
+	})
+	if !ok {
+		return nil
+	}
+	return sole
+}
+
+// resultTypeExpr returns the type expression of result i of results.
+func resultTypeExpr(results *ast.FieldList, i int) ast.Expr {
+	for _, field := range results.List {
+		n := len(field.Names)
+		if n == 0 {
+			n = 1
+		}
+		if i < n {
+			return field.Type
+		}
+		i -= n
+	}
+	return nil
+}
+
+// MapAccessWithoutOK reports map index expressions in the file uri whose
+// result is immediately dereferenced, by selecting a field or method,
+// calling it or indirecting it, while the map holds pointers, interfaces or
+// functions. Such code assumes the key is present, and panics on the nil
+// value of a missing key. This is a heuristic: the key may be known to be
+// present.
+func MapAccessWithoutOK(pkg Package, fset *token.FileSet, uri span.URI) ([]Diagnostic, error) {
+	file, err := lintFile(pkg, fset, uri)
+	if err != nil {
+		return nil, err
+	}
+
+	info := pkg.GetTypesInfo()
+	var diags []Diagnostic
+	check := func(x ast.Expr, what string) {
+		index, ok := astutil.Unparen(x).(*ast.IndexExpr)
+		if !ok {
+			return
+		}
+		// The operand has no type if it does not type-check.
+		typ := info.TypeOf(index.X)
+		if typ == nil {
+			return
+		}
+		m, ok := typ.Underlying().(*types.Map)
+		if !ok {
+			return
+		}
+		switch m.Elem().Underlying().(type) {
+		case *types.Pointer, *types.Interface, *types.Signature:
+			diags = append(diags, lintDiagnostic(fset, index, "mapaccess", "%s of %s assumes the key is present; check with v, ok := %s", what, types.ExprString(index), types.ExprString(index)))
+		}
+	}
+
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.SelectorExpr:
+			check(n.X, "selection")
+		case *ast.CallExpr:
+			check(n.Fun, "call")
+		case *ast.StarExpr:
+			check(n.X, "indirection")
+		}
+		return true
+	})
+
+	return diags, nil
+}
+
+// LossyConversions reports conversions between numeric types in the file
+// uri that may overflow or lose precision, such as int64 to int32, int to
+// uint, float64 to float32, float64 to int, or int64 to float64. Constant
+// conversions, which the compiler checks, are not reported.
+func LossyConversions(pkg Package, fset *token.FileSet, uri span.URI) ([]Diagnostic, error) {
+	file, err := lintFile(pkg, fset, uri)
+	if err != nil {
+		return nil, err
+	}
+
+	info := pkg.GetTypesInfo()
+	sizes := pkg.GetTypesSizes()
+	if sizes == nil {
+		sizes = types.SizesFor("gc", "amd64")
+	}
+	var diags []Diagnostic
+	ast.Inspect(file, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || len(call.Args) != 1 {
+			return true
+		}
+		if tv, ok := info.Types[call.Fun]; !ok || !tv.IsType() {
+			return true
+		}
+		if tv := info.Types[call.Args[0]]; tv.Value != nil {
+			return true
+		}
+		// The operand has no type if it does not type-check.
+		fromType, toType := info.TypeOf(call.Args[0]), info.TypeOf(call.Fun)
+		if fromType == nil || toType == nil {
+			return true
+		}
+		from, _ := fromType.Underlying().(*types.Basic)
+		to, _ := toType.Underlying().(*types.Basic)
+		if from == nil || to == nil {
+			return true
+		}
+		if reason := lossyConversion(sizes, from, to); reason != "" {
+			diags = append(diags, lintDiagnostic(fset, call, "lossyconv", "conversion from %s to %s may %s", from, to, reason))
+		}
+		return true
+	})
+
+	return diags, nil
+}
+
+// lossyConversion returns how converting a value of type from to type to
+// may lose data, or "" if it cannot.
+func lossyConversion(sizes types.Sizes, from, to *types.Basic) string {
+	const (
+		integer  = types.IsInteger
+		float    = types.IsFloat
+		unsigned = types.IsUnsigned
+	)
+	is := func(t *types.Basic, flag types.BasicInfo) bool { return t.Info()&flag != 0 }
+	bits := func(t *types.Basic) int64 { return 8 * sizes.Sizeof(t) }
+	// mantissa is the number of significant bits of a float type.
+	mantissa := func(t *types.Basic) int64 {
+		if t.Kind() == types.Float32 {
+			return 24
+		}
+		return 53
+	}
+
+	switch {
+	case is(from, integer) && is(to, integer):
+		switch {
+		case bits(to) < bits(from):
+			return "overflow"
+		case is(from, unsigned) != is(to, unsigned) && !(is(from, unsigned) && bits(to) > bits(from)):
+			return "change sign"
+		}
+	case is(from, float) && is(to, integer):
+		return "truncate"
+	case is(from, float) && is(to, float):
+		if bits(to) < bits(from) {
+			return "lose precision"
+		}
+	case is(from, integer) && is(to, float):
+		if bits(from) > mantissa(to) {
+			return "lose precision"
+		}
+	}
+	return ""
+}
+
+// LoopVarPointer reports the addresses of range loop variables in the file
+// uri that escape the iteration by being returned, sent on a channel, or
+// stored in a variable declared outside the loop, such as
+// 'out = append(out, &v)'. Before Go 1.22, all iterations share the loop
+// variables, so every stored pointer ends up pointing to the last element.
+// Copying the variable first, as in 'v := v', avoids the problem.
+func LoopVarPointer(pkg Package, fset *token.FileSet, uri span.URI) ([]Diagnostic, error) {
+	file, err := lintFile(pkg, fset, uri)
+	if err != nil {
+		return nil, err
+	}
+
+	info := pkg.GetTypesInfo()
+	var diags []Diagnostic
+	ast.Inspect(file, func(n ast.Node) bool {
+		loop, ok := n.(*ast.RangeStmt)
+		if !ok || loop.Tok != token.DEFINE {
+			return true
+		}
+		vars := make(map[types.Object]bool)
+		for _, x := range []ast.Expr{loop.Key, loop.Value} {
+			if id, ok := x.(*ast.Ident); ok && info.Defs[id] != nil {
+				vars[info.Defs[id]] = true
+			}
+		}
+
+		var stack []ast.Node
+		ast.Inspect(loop.Body, func(n ast.Node) bool {
+			if n == nil {
+				stack = stack[:len(stack)-1]
+				return true
+			}
+			stack = append(stack, n)
+			u, ok := n.(*ast.UnaryExpr)
+			if !ok || u.Op != token.AND {
+				return true
+			}
+			id, ok := astutil.Unparen(u.X).(*ast.Ident)
+			if !ok || !vars[info.Uses[id]] {
+				return true
+			}
+			if escapesLoop(info, loop, stack) {
+				diags = append(diags, lintDiagnostic(fset, u, "loopvarptr", "%s is the address of loop variable %s, which is shared by all iterations", types.ExprString(u), id.Name))
+			}
+			return true
+		})
+		return true
+	})
+
+	return diags, nil
+}
+
+// escapesLoop reports whether the value of the last node of stack, an
+// expression within the body of loop, outlives the iteration. The value
+// escapes through parentheses, composite literals and append calls into a
+// return or send statement, or an assignment to a variable declared outside
+// the loop or to a field, element or pointee.
+func escapesLoop(info *types.Info, loop *ast.RangeStmt, stack []ast.Node) bool {
+	child := stack[len(stack)-1]
+	for i := len(stack) - 2; i >= 0; i-- {
+		switch n := stack[i].(type) {
+		case *ast.ParenExpr, *ast.CompositeLit, *ast.KeyValueExpr:
+		case *ast.CallExpr:
+			if id, ok := astutil.Unparen(n.Fun).(*ast.Ident); !ok || info.Uses[id] != types.Universe.Lookup("append") {
+				return false
+			}
+		case *ast.ReturnStmt:
+			return true
+		case *ast.SendStmt:
+			return n.Value == child
+		case *ast.AssignStmt:
+			if len(n.Lhs) != len(n.Rhs) {
+				return false
+			}
+			for j, rhs := range n.Rhs {
+				if rhs != child {
+					continue
+				}
+				id, ok := astutil.Unparen(n.Lhs[j]).(*ast.Ident)
+				if !ok {
+					return true
+				}
+				obj := info.ObjectOf(id)
+				return obj != nil && obj.Name() != "_" && (obj.Pos() < loop.Pos() || obj.Pos() >= loop.End())
+			}
+			return false
+		default:
+			return false
+		}
+		child = stack[i]
+	}
+	return false
+}
+
+// LoopVarGoroutine reports the references to range loop variables in the
+// file uri from function literals run by go or defer statements of the
+// loop, such as v in 'go func() { use(v) }()'. Before Go 1.22, all
+// iterations share the loop variables, so the function sees the value of a
+// later iteration. Passing the variable as an argument, as in
+// 'go func(v T) { use(v) }(v)', evaluates it immediately and is safe.
+func LoopVarGoroutine(pkg Package, fset *token.FileSet, uri span.URI) ([]Diagnostic, error) {
+	file, err := lintFile(pkg, fset, uri)
+	if err != nil {
+		return nil, err
+	}
+
+	info := pkg.GetTypesInfo()
+	var diags []Diagnostic
+	ast.Inspect(file, func(n ast.Node) bool {
+		loop, ok := n.(*ast.RangeStmt)
+		if !ok || loop.Tok != token.DEFINE {
+			return true
+		}
+		vars := make(map[types.Object]bool)
+		for _, x := range []ast.Expr{loop.Key, loop.Value} {
+			if id, ok := x.(*ast.Ident); ok && info.Defs[id] != nil {
+				vars[info.Defs[id]] = true
+			}
+		}
+
+		ast.Inspect(loop.Body, func(n ast.Node) bool {
+			var call *ast.CallExpr
+			var stmt string
+			switch n := n.(type) {
+			case *ast.GoStmt:
+				call, stmt = n.Call, "go"
+			case *ast.DeferStmt:
+				call, stmt = n.Call, "defer"
+			default:
+				return true
+			}
+			lit, ok := astutil.Unparen(call.Fun).(*ast.FuncLit)
+			if !ok {
+				return true
+			}
+			ast.Inspect(lit.Body, func(n ast.Node) bool {
+				if id, ok := n.(*ast.Ident); ok && vars[info.Uses[id]] {
+					diags = append(diags, lintDiagnostic(fset, id, "loopvargo", "loop variable %s captured by func literal in %s statement; pass it as an argument", id.Name, stmt))
+				}
+				return true
+			})
+			return true
+		})
+		return true
+	})
+
+	return diags, nil
+}
@@ -0,0 +1,494 @@
+package source
+
+import (
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/internal/span"
+)
+
+type lintFunc func(pkg Package, fset *token.FileSet, uri span.URI) ([]Diagnostic, error)
+
+// runLint type-checks src as the single file lint.go of package lint and
+// runs lint on it, returning the source text spanned by each diagnostic.
+func runLint(t *testing.T, lint lintFunc, src string) []string {
+	t.Helper()
+
+	fset, pkgs := loadTestPackages(t, testSource{path: "lint", files: map[string]string{"lint.go": src}})
+	if errs := pkgs[0].errors; len(errs) > 0 {
+		t.Fatalf("test source does not type-check: %v", errs)
+	}
+	return lintTexts(t, lint, fset, pkgs[0])
+}
+
+// lintTexts runs lint on the single file of pkg, returning the source text
+// spanned by each diagnostic.
+func lintTexts(t *testing.T, lint lintFunc, fset *token.FileSet, pkg *testPackage) []string {
+	t.Helper()
+
+	diags, err := lint(pkg, fset, span.FileURI(pkg.filenames[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, d := range diags {
+		got = append(got, pkg.contents[0][d.Start().Offset():d.End().Offset()])
+	}
+	return got
+}
+
+// checkLintTypeErrors is checkLint for a source with type errors, such as
+// the code being edited, on which lints must not fail.
+func checkLintTypeErrors(t *testing.T, lint lintFunc, src string, want ...string) {
+	t.Helper()
+
+	fset, pkgs := loadTestPackages(t, testSource{path: "lint", files: map[string]string{"lint.go": src}})
+	if len(pkgs[0].errors) == 0 {
+		t.Fatalf("test source type-checks")
+	}
+	got := lintTexts(t, lint, fset, pkgs[0])
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got diagnostics on %q, want %q", got, want)
+	}
+}
+
+func checkLint(t *testing.T, lint lintFunc, src string, want ...string) {
+	t.Helper()
+
+	got := runLint(t, lint, src)
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got diagnostics on %q, want %q", got, want)
+	}
+}
+
+func TestLockCopy(t *testing.T) {
+	checkLint(t, LockCopy, `package lint
+
+import "sync"
+
+type Counter struct {
+	mu sync.Mutex
+	n  int
+}
+
+func byValue(c Counter) int { return c.n }
+
+func byPointer(c *Counter) int { return c.n }
+`, "Counter")
+}
+
+func TestFileUnusedImports(t *testing.T) {
+	fset, pkgs := loadTestPackages(t, testSource{path: "imports", files: map[string]string{
+		"a.go": `package imports
+
+import "strings"
+
+func Upper(s string) string { return strings.ToUpper(s) }
+`,
+		"b.go": `package imports
+
+import (
+	"fmt"
+	"strings"
+)
+
+func Print(s string) { fmt.Println(s) }
+`,
+	}})
+	p := pkgs[0]
+
+	for i, want := range []string{"", `"strings"`} {
+		diags, err := FileUnusedImports(p, fset, span.FileURI(p.filenames[i]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, d := range diags {
+			got = append(got, p.contents[i][d.Start().Offset():d.End().Offset()])
+		}
+		if strings.Join(got, " ") != want {
+			t.Errorf("%s: got unused imports %q, want %q", p.filenames[i], got, want)
+		}
+	}
+}
+
+func TestContextFirstParam(t *testing.T) {
+	checkLint(t, ContextFirstParam, `package lint
+
+import "context"
+
+func first(ctx context.Context, name string) {}
+
+func second(name string, c context.Context) {}
+`, "c context.Context")
+}
+
+func TestStringConcatInLoop(t *testing.T) {
+	checkLint(t, StringConcatInLoop, `package lint
+
+func join(words []string) string {
+	s := ""
+	for _, w := range words {
+		s += w
+	}
+	t := ""
+	for i := 0; i < len(words); i++ {
+		t = t + words[i] + ","
+	}
+	return s + t
+}
+
+func once(a, b string) string {
+	s := a
+	s += b
+	for range a {
+		u := ""
+		u += b
+		_ = u
+	}
+	return s
+}
+`, "s += w", `t = t + words[i] + ","`)
+}
+
+func TestRedundantConversions(t *testing.T) {
+	checkLint(t, RedundantConversions, `package lint
+
+import "io"
+
+type Celsius float64
+
+const (
+	limit       = 10
+	typed int64 = 20
+)
+
+func f(i int, f float64, r io.Reader, c Celsius) {
+	_ = int(i)
+	_ = float64(i)
+	_ = Celsius(f)
+	_ = Celsius(c)
+	_ = int64(5)
+	_ = int64(limit * 2)
+	_ = int64(typed)
+	_ = io.Reader(r)
+	_ = interface{}(i)
+}
+`, "int(i)", "Celsius(c)", "int64(typed)", "io.Reader(r)")
+}
+
+func TestPrintfCheck(t *testing.T) {
+	checkLint(t, PrintfCheck, `package lint
+
+import (
+	"errors"
+	"fmt"
+)
+
+type id int
+
+func (id) String() string { return "" }
+
+func logf(format string, args ...interface{}) {}
+
+func f(name string, n int, err error, i id) {
+	fmt.Printf("%s has %d items\n", name, n)
+	fmt.Printf("%d%% of %v, %s\n", n, err, i)
+	_ = fmt.Errorf("loading %s: %w", name, err)
+	_ = fmt.Sprintf("%*d", n, n)
+	_ = fmt.Sprintf("%[1]d %[1]s", n)
+	// The variadic signature of append with a string has no slice parameter.
+	_ = append([]byte("%d"), name...)
+	// The verb applies to the elements of composite values.
+	fmt.Printf("%s %d\n", []string{"a"}, []int{1})
+	fmt.Printf("%d %s %v %p\n", map[int]int{}, &struct{ s string }{}, []*int{}, []int{})
+	fmt.Printf("%d %s\n", []string{"a"}, [1]int{})
+
+	fmt.Printf("%d items\n", name)
+	logf("%s and %s", name)
+	_ = fmt.Sprintf("%s", name, n)
+	_ = fmt.Errorf("%w", errors.New("x").Error())
+}
+`, `[]string{"a"}`, `[1]int{}`, "name", `logf("%s and %s", name)`, `fmt.Sprintf("%s", name, n)`, `errors.New("x").Error()`)
+}
+
+func TestEmptyInterfaceUsage(t *testing.T) {
+	checkLint(t, EmptyInterfaceUsage, `package lint
+
+import "io"
+
+type Box struct {
+	Value interface{}
+	R     io.Reader
+}
+
+func store(key string, v any) {}
+
+func log(format string, args ...interface{}) (interface{}, error) { return nil, nil }
+
+func read(r io.Reader, x interface{ Close() error }) {}
+
+func first[T any](v T) T {
+	_, _ = interface{}(v).(string)
+	return v
+}
+`, "interface{}", "any", "interface{}", "interface{}")
+}
+
+func TestBuiltinShadow(t *testing.T) {
+	checkLint(t, BuiltinShadow, `package lint
+
+type Buffer struct {
+	len int
+}
+
+func (b Buffer) cap() int { return 0 }
+
+func size(items []string, copy bool) int {
+	len := 0
+	for _, item := range items {
+		len += 1
+		_ = item
+	}
+	count := len
+	_ = copy
+	return count
+}
+`, "copy", "len")
+}
+
+func TestReturnConcreteHint(t *testing.T) {
+	checkLint(t, ReturnConcreteHint, `package lint
+
+import (
+	"bytes"
+	"io"
+	"strings"
+)
+
+func open(s string) (io.Reader, error) {
+	if s == "" {
+		return nil, nil
+	}
+	return strings.NewReader(s), nil
+}
+
+func choose(s string) io.Reader {
+	if s == "" {
+		return &bytes.Buffer{}
+	}
+	return strings.NewReader(s)
+}
+
+type emptyError struct{}
+
+func (*emptyError) Error() string { return "empty" }
+
+func check(s string) error {
+	if s == "" {
+		return &emptyError{}
+	}
+	return nil
+}
+
+func forward(s string) (io.Reader, error) {
+	return open(s)
+}
+`, "io.Reader")
+
+	checkLintTypeErrors(t, ReturnConcreteHint, `package lint
+
+import "io"
+
+func (r *undefinedType) Read() io.Reader { return r }
+
+func open() io.Reader { return undefinedVar }
+
+func open() io.Reader { return nil }
+`)
+}
+
+func TestMapAccessWithoutOK(t *testing.T) {
+	checkLint(t, MapAccessWithoutOK, `package lint
+
+type Conn struct{ addr string }
+
+func (c *Conn) Close() error { return nil }
+
+type Point struct{ X int }
+
+func f(conns map[string]*Conn, handlers map[string]func(), points map[string]Point, k string) {
+	conns[k].Close()
+	handlers[k]()
+	_ = *conns[k]
+	_ = points[k].X
+	if c, ok := conns[k]; ok {
+		c.Close()
+	}
+	c := conns[k]
+	_ = c
+}
+`, "conns[k]", "handlers[k]", "conns[k]")
+
+	checkLintTypeErrors(t, MapAccessWithoutOK, `package lint
+
+type Conn struct{}
+
+func (c *Conn) Close() error { return nil }
+
+func f(conns map[string]*Conn, k string) {
+	undefinedMap[k].M()
+	conns[k].Close()
+}
+`, "conns[k]")
+}
+
+func TestLossyConversions(t *testing.T) {
+	checkLint(t, LossyConversions, `package lint
+
+func f(i64 int64, i32 int32, i int, u8 uint8, f64 float64, f32 float32) {
+	_ = int32(i64)
+	_ = int64(i32)
+	_ = uint(i)
+	_ = int(u8)
+	_ = float32(f64)
+	_ = float64(f32)
+	_ = int(f64)
+	_ = float64(i64)
+	_ = float64(i32)
+	_ = int8(300 >> 2)
+}
+`, "int32(i64)", "uint(i)", "float32(f64)", "int(f64)", "float64(i64)")
+
+	checkLintTypeErrors(t, LossyConversions, `package lint
+
+func f(i64 int64) {
+	_ = int32(undefinedVar)
+	_ = undefinedType(i64)
+	_ = int32(i64)
+}
+`, "int32(i64)")
+}
+
+func TestLintSymlinkedDir(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "lint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	const src = "package lint\n\nfunc f() { len := 0; _ = len }\n"
+	real, link := filepath.Join(tmp, "real"), filepath.Join(tmp, "link")
+	if err := os.Mkdir(real, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(real, "lint.go"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(real, link); err != nil {
+		t.Skipf("cannot create symlink: %v", err)
+	}
+
+	// The package is loaded from its real directory, as the go command
+	// reports it, while the editor may name the file through the link.
+	fset, pkgs := loadTestPackages(t, testSource{path: "lint", dir: real, files: map[string]string{"lint.go": src}})
+	for _, dir := range []string{real, link} {
+		diags, err := BuiltinShadow(pkgs[0], fset, span.FileURI(filepath.Join(dir, "lint.go")))
+		if err != nil {
+			t.Errorf("%s: %v", dir, err)
+			continue
+		}
+		if len(diags) != 1 {
+			t.Errorf("%s: got %d diagnostics, want 1", dir, len(diags))
+		}
+	}
+	if _, err := BuiltinShadow(pkgs[0], fset, span.FileURI(filepath.Join(tmp, "lint.go"))); err == nil {
+		t.Error("found a file outside the package")
+	}
+}
+
+func TestLoopVarPointer(t *testing.T) {
+	checkLint(t, LoopVarPointer, `package lint
+
+type item struct{ name string }
+
+func collect(items []item) []*item {
+	var out []*item
+	for _, it := range items {
+		out = append(out, &it)
+	}
+	return out
+}
+
+func index(items []item) map[string]*item {
+	m := make(map[string]*item)
+	for _, it := range items {
+		m[it.name] = &it
+	}
+	return m
+}
+
+func first(items []item, ch chan *item) *item {
+	for i, it := range items {
+		if i > 0 {
+			ch <- &it
+		}
+		return &it
+	}
+	return nil
+}
+
+func safe(items []item) []*item {
+	var out []*item
+	for i, it := range items {
+		it := it
+		out = append(out, &it)
+		out = append(out, &items[i])
+	}
+	return out
+}
+
+func local(items []item) {
+	for _, it := range items {
+		p := &it
+		print(p.name, &it == nil)
+	}
+}
+`, "&it", "&it", "&it", "&it")
+}
+
+func TestLoopVarGoroutine(t *testing.T) {
+	checkLint(t, LoopVarGoroutine, `package lint
+
+import "sync"
+
+func process(items []string, wg *sync.WaitGroup) {
+	for i, item := range items {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			println(i, item)
+		}()
+	}
+	for _, item := range items {
+		defer func() { println(item) }()
+	}
+}
+
+func safe(items []string, wg *sync.WaitGroup) {
+	for i, item := range items {
+		go func(i int, item string) {
+			println(i, item)
+		}(i, item)
+		item := item
+		go func() { println(item) }()
+		go println(i)
+	}
+}
+`, "i", "item", "item")
+}
@@ -0,0 +1,384 @@
+package source
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/internal/span"
+)
+
+// FuncComplexity is the cyclomatic complexity of a function.
+type FuncComplexity struct {
+	// Name is the function name, prefixed by its receiver type name for
+	// methods, such as "T.M".
+	Name       string
+	Location   Location
+	Complexity int
+}
+
+// CyclomaticComplexity computes the McCabe cyclomatic complexity of each
+// function declared in the file uri: one plus the number of if, for and
+// range statements, non-default case and select clauses, and && and ||
+// operators in its body, including those of nested function literals.
+func CyclomaticComplexity(pkg Package, fset *token.FileSet, uri span.URI) ([]FuncComplexity, error) {
+	file, err := lintFile(pkg, fset, uri)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []FuncComplexity
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Body == nil {
+			continue
+		}
+		results = append(results, FuncComplexity{
+			Name:       funcDeclName(fn),
+			Location:   toLocation(fset, fn.Name.Pos(), fn.Name.Name),
+			Complexity: complexity(fn.Body),
+		})
+	}
+
+	return results, nil
+}
+
+func complexity(body *ast.BlockStmt) int {
+	c := 1
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.IfStmt, *ast.ForStmt, *ast.RangeStmt:
+			c++
+		case *ast.CaseClause:
+			if n.List != nil {
+				c++
+			}
+		case *ast.CommClause:
+			if n.Comm != nil {
+				c++
+			}
+		case *ast.BinaryExpr:
+			if n.Op == token.LAND || n.Op == token.LOR {
+				c++
+			}
+		}
+		return true
+	})
+	return c
+}
+
+// funcDeclName returns the name of fn, prefixed by its receiver type name
+// if it is a method.
+func funcDeclName(fn *ast.FuncDecl) string {
+	if fn.Recv == nil || len(fn.Recv.List) == 0 {
+		return fn.Name.Name
+	}
+
+	typ := fn.Recv.List[0].Type
+	for {
+		switch t := typ.(type) {
+		case *ast.StarExpr:
+			typ = t.X
+			continue
+		case *ast.ParenExpr:
+			typ = t.X
+			continue
+		case *ast.Ident:
+			return t.Name + "." + fn.Name.Name
+		}
+		return fn.Name.Name
+	}
+}
+
+// LongParameterLists returns the functions and methods declared in pkg that
+// take more than threshold parameters.
+func LongParameterLists(pkg Package, fset *token.FileSet, threshold int) []Symbol {
+	info := pkg.GetTypesInfo()
+	var symbols []Symbol
+	for _, f := range pkg.GetSyntax() {
+		q := qualifier(f, pkg.GetTypes(), info)
+		for _, decl := range f.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok {
+				continue
+			}
+			obj, ok := info.Defs[fn.Name].(*types.Func)
+			if !ok {
+				continue
+			}
+			if obj.Type().(*types.Signature).Params().Len() > threshold {
+				symbols = append(symbols, funcSymbol(fn, obj, fset, q))
+			}
+		}
+	}
+
+	return symbols
+}
+
+// exitFuncs are the functions of the standard library that never return.
+var exitFuncs = map[string]bool{
+	"os.Exit":                   true,
+	"runtime.Goexit":            true,
+	"log.Fatal":                 true,
+	"log.Fatalf":                true,
+	"log.Fatalln":               true,
+	"log.Panic":                 true,
+	"log.Panicf":                true,
+	"log.Panicln":               true,
+	"(*log.Logger).Fatal":       true,
+	"(*log.Logger).Fatalf":      true,
+	"(*log.Logger).Fatalln":     true,
+	"(*log.Logger).Panic":       true,
+	"(*log.Logger).Panicf":      true,
+	"(*log.Logger).Panicln":     true,
+	"(*testing.common).FailNow": true,
+	"(*testing.common).Fatal":   true,
+	"(*testing.common).Fatalf":  true,
+	"(*testing.common).SkipNow": true,
+	"(*testing.common).Skip":    true,
+	"(*testing.common).Skipf":   true,
+}
+
+// NoReturnFunctions returns the functions and methods declared in pkg whose
+// every path ends in a panic, a call to a function that never returns, such
+// as os.Exit or another function of the result, or an infinite loop.
+// Analyses of unreachable code can treat calls to them as terminating.
+func NoReturnFunctions(pkg Package, fset *token.FileSet) []Symbol {
+	info := pkg.GetTypesInfo()
+	type funcDecl struct {
+		decl *ast.FuncDecl
+		obj  *types.Func
+		file *ast.File
+	}
+	var decls []funcDecl
+	for _, f := range pkg.GetSyntax() {
+		for _, decl := range f.Decls {
+			if fn, ok := decl.(*ast.FuncDecl); ok && fn.Body != nil {
+				if obj, ok := info.Defs[fn.Name].(*types.Func); ok {
+					decls = append(decls, funcDecl{fn, obj, f})
+				}
+			}
+		}
+	}
+
+	// A function calling another one that never returns may itself never
+	// return, so iterate until no more functions are found.
+	c := &noReturnChecker{info: info, funcs: make(map[*types.Func]bool)}
+	for changed := true; changed; {
+		changed = false
+		for _, d := range decls {
+			if !c.funcs[d.obj] && c.list(d.decl.Body.List) {
+				c.funcs[d.obj] = true
+				changed = true
+			}
+		}
+	}
+
+	var symbols []Symbol
+	for _, d := range decls {
+		if c.funcs[d.obj] {
+			symbols = append(symbols, funcSymbol(d.decl, d.obj, fset, qualifier(d.file, pkg.GetTypes(), info)))
+		}
+	}
+	return symbols
+}
+
+// noReturnChecker reports whether statements never complete normally.
+type noReturnChecker struct {
+	info *types.Info
+	// funcs holds the functions of the package known to never return.
+	funcs map[*types.Func]bool
+}
+
+// list reports whether the statement list never returns: some statement of
+// it never returns, and no statement before it may return.
+func (c *noReturnChecker) list(stmts []ast.Stmt) bool {
+	for _, s := range stmts {
+		if c.stmt(s, "") {
+			return true
+		}
+		if hasReturn(s) {
+			return false
+		}
+	}
+	return false
+}
+
+// stmt reports whether s never completes normally, label being the label
+// of s if any.
+func (c *noReturnChecker) stmt(s ast.Stmt, label string) bool {
+	switch s := s.(type) {
+	case *ast.ExprStmt:
+		call, ok := s.X.(*ast.CallExpr)
+		return ok && c.noReturnCall(call)
+	case *ast.BlockStmt:
+		return c.list(s.List)
+	case *ast.LabeledStmt:
+		return c.stmt(s.Stmt, s.Label.Name)
+	case *ast.IfStmt:
+		return s.Else != nil && c.stmt(s.Body, "") && c.stmt(s.Else, "")
+	case *ast.ForStmt:
+		return s.Cond == nil && !hasReturn(s.Body) && !hasBreak(s.Body, label)
+	case *ast.SelectStmt:
+		for _, cc := range s.Body.List {
+			if !c.list(cc.(*ast.CommClause).Body) {
+				return false
+			}
+		}
+		return !hasBreak(s.Body, label)
+	case *ast.SwitchStmt:
+		return c.clauses(s.Body, label)
+	case *ast.TypeSwitchStmt:
+		return c.clauses(s.Body, label)
+	}
+	return false
+}
+
+// clauses reports whether a switch statement with body never completes
+// normally: it has a default case and no case completes or breaks.
+func (c *noReturnChecker) clauses(body *ast.BlockStmt, label string) bool {
+	hasDefault := false
+	for _, cc := range body.List {
+		cc := cc.(*ast.CaseClause)
+		if cc.List == nil {
+			hasDefault = true
+		}
+		if !c.list(cc.Body) {
+			return false
+		}
+	}
+	return hasDefault && !hasBreak(body, label)
+}
+
+// noReturnCall reports whether call is a call to panic or to a function
+// that never returns.
+func (c *noReturnChecker) noReturnCall(call *ast.CallExpr) bool {
+	var id *ast.Ident
+	switch fun := call.Fun.(type) {
+	case *ast.Ident:
+		id = fun
+	case *ast.SelectorExpr:
+		id = fun.Sel
+	default:
+		return false
+	}
+	switch obj := c.info.Uses[id].(type) {
+	case *types.Builtin:
+		return obj.Name() == "panic"
+	case *types.Func:
+		return c.funcs[obj] || exitFuncs[obj.FullName()]
+	}
+	return false
+}
+
+// hasReturn reports whether s contains a return statement, outside of
+// function literals.
+func hasReturn(s ast.Stmt) bool {
+	found := false
+	ast.Inspect(s, func(n ast.Node) bool {
+		switch n.(type) {
+		case *ast.ReturnStmt:
+			found = true
+		case *ast.FuncLit:
+			return false
+		}
+		return !found
+	})
+	return found
+}
+
+// hasBreak reports whether body contains a break statement leaving the
+// statement it belongs to: an unlabeled break outside of nested loops,
+// switch and select statements, or a break to label.
+func hasBreak(body *ast.BlockStmt, label string) bool {
+	found := false
+	var inspect func(n ast.Node, nested bool) bool
+	inspect = func(n ast.Node, nested bool) bool {
+		switch n := n.(type) {
+		case *ast.BranchStmt:
+			if n.Tok == token.BREAK && (n.Label == nil && !nested || n.Label != nil && n.Label.Name == label) {
+				found = true
+			}
+		case *ast.FuncLit:
+			return false
+		case *ast.ForStmt, *ast.RangeStmt, *ast.SwitchStmt, *ast.TypeSwitchStmt, *ast.SelectStmt:
+			if !nested {
+				ast.Inspect(n, func(m ast.Node) bool { return m == n || inspect(m, true) })
+				return false
+			}
+		}
+		return !found
+	}
+	ast.Inspect(body, func(n ast.Node) bool { return inspect(n, false) })
+	return found
+}
+
+// ChainInfo is a chain of selections, such as a.b().c().d().
+type ChainInfo struct {
+	// Chain is the chain expression as written, such as "a.b().c().d()".
+	Chain string
+	// Depth is the number of selections of the chain, 3 for a.b().c().d().
+	// Qualified identifiers such as fmt.Sprintf are not counted.
+	Depth    int
+	Location Location
+}
+
+// MethodChainDepth returns the chains of selections and calls in the file
+// uri that are deeper than maxDepth. Only the outermost chain is reported,
+// not the shorter chains it is built on.
+func MethodChainDepth(pkg Package, fset *token.FileSet, uri span.URI, maxDepth int) ([]ChainInfo, error) {
+	file, err := lintFile(pkg, fset, uri)
+	if err != nil {
+		return nil, err
+	}
+
+	info := pkg.GetTypesInfo()
+	// links holds the inner expressions of the chains already visited.
+	links := make(map[ast.Expr]bool)
+	var chains []ChainInfo
+	ast.Inspect(file, func(n ast.Node) bool {
+		x, ok := n.(ast.Expr)
+		if !ok || links[x] {
+			return true
+		}
+		depth := 0
+		for link := x; ; {
+			switch e := link.(type) {
+			case *ast.CallExpr:
+				link = e.Fun
+			case *ast.ParenExpr:
+				link = e.X
+			case *ast.IndexExpr:
+				link = e.X
+			case *ast.SelectorExpr:
+				if id, ok := e.X.(*ast.Ident); !ok || !isPkgName(info, id) {
+					depth++
+				}
+				link = e.X
+			default:
+				link = nil
+			}
+			if link == nil {
+				break
+			}
+			links[link] = true
+		}
+		if depth > maxDepth {
+			spn, _ := nodeSpan(x, fset)
+			chains = append(chains, ChainInfo{
+				Chain:    types.ExprString(x),
+				Depth:    depth,
+				Location: Location{Span: spn},
+			})
+		}
+		return true
+	})
+
+	return chains, nil
+}
+
+// isPkgName reports whether id refers to an imported package.
+func isPkgName(info *types.Info, id *ast.Ident) bool {
+	_, ok := info.Uses[id].(*types.PkgName)
+	return ok
+}
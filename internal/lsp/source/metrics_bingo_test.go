@@ -0,0 +1,190 @@
+package source
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/internal/span"
+)
+
+func TestCyclomaticComplexity(t *testing.T) {
+	fset, pkgs := loadTestPackages(t, testSource{path: "cyclo", files: map[string]string{"cyclo.go": `package cyclo
+
+func straight() int { return 1 }
+
+func branches(a, b bool, xs []int) int {
+	n := 0
+	if a && b {
+		n++
+	}
+	for _, x := range xs {
+		switch x {
+		case 1, 2:
+			n++
+		case 3:
+			n--
+		default:
+		}
+	}
+	return n
+}
+
+type T struct{}
+
+func (*T) loop(n int) {
+	for i := 0; i < n || n < 0; i++ {
+	}
+}
+`}})
+	p := pkgs[0]
+
+	got, err := CyclomaticComplexity(p, fset, span.FileURI(p.filenames[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		name       string
+		complexity int
+	}{
+		{"straight", 1},
+		{"branches", 6},
+		{"T.loop", 3},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d functions, want %d", len(got), len(want))
+	}
+	for i, w := range want {
+		if got[i].Name != w.name || got[i].Complexity != w.complexity {
+			t.Errorf("got %s=%d, want %s=%d", got[i].Name, got[i].Complexity, w.name, w.complexity)
+		}
+	}
+}
+
+func TestLongParameterLists(t *testing.T) {
+	fset, pkgs := loadTestPackages(t, testSource{path: "params", files: map[string]string{"params.go": `package params
+
+func few(a, b int) {}
+
+func many(a, b, c int, d string) {}
+
+type T struct{}
+
+func (T) method(a, b, c, d, e int) {}
+`}})
+
+	got := LongParameterLists(pkgs[0], fset, 3)
+	if len(got) != 2 || got[0].Name != "many" || got[1].Name != "method" {
+		t.Errorf("got %v, want many and method", got)
+	}
+}
+
+func TestNoReturnFunctions(t *testing.T) {
+	fset, pkgs := loadTestPackages(t, testSource{path: "noret", files: map[string]string{"noret.go": `package noret
+
+import (
+	"log"
+	"os"
+)
+
+func fail(msg string) {
+	panic(msg)
+}
+
+func normal(x int) int {
+	if x < 0 {
+		fail("negative")
+	}
+	return x
+}
+
+func exit(code int) {
+	if code != 0 {
+		os.Exit(code)
+	} else {
+		log.Fatal("done")
+	}
+}
+
+func wrapped() {
+	fail("wrapped")
+}
+
+func serve(ch chan int) {
+	for {
+		<-ch
+	}
+}
+
+func loopWithBreak(ch chan int) {
+	for {
+		if <-ch == 0 {
+			break
+		}
+	}
+}
+
+func loopWithReturn(ch chan int) {
+	for {
+		select {
+		case <-ch:
+			return
+		}
+	}
+}
+
+func check(ok bool) {
+	switch {
+	case ok:
+		panic("ok")
+	default:
+		panic("not ok")
+	}
+}
+
+func mayReturn(ok bool) {
+	if ok {
+		return
+	}
+	panic("not ok")
+}
+`}})
+
+	var got []string
+	for _, s := range NoReturnFunctions(pkgs[0], fset) {
+		got = append(got, s.Name)
+	}
+	if want := "fail exit wrapped serve check"; strings.Join(got, " ") != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestMethodChainDepth(t *testing.T) {
+	fset, pkgs := loadTestPackages(t, testSource{path: "chain", files: map[string]string{"chain.go": `package chain
+
+import "strings"
+
+type Builder struct{ parts []string }
+
+func New() *Builder                     { return &Builder{} }
+func (b *Builder) Add(s string) *Builder { b.parts = append(b.parts, s); return b }
+func (b *Builder) String() string       { return strings.Join(b.parts, " ") }
+
+func build(b *Builder) string {
+	short := b.Add("a").String()
+	long := New().Add("a").Add("b").Add(strings.ToUpper("c")).String()
+	return short + long
+}
+`}})
+	p := pkgs[0]
+
+	chains, err := MethodChainDepth(p, fset, span.FileURI(p.filenames[0]), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chains) != 1 {
+		t.Fatalf("got %d chains, want 1: %v", len(chains), chains)
+	}
+	if got := chains[0]; got.Chain != `New().Add("a").Add("b").Add(strings.ToUpper("c")).String()` || got.Depth != 4 || got.Location.Span.Start().Line() != 13 {
+		t.Errorf("got chain %s of depth %d on line %d, want the chain of long", got.Chain, got.Depth, got.Location.Span.Start().Line())
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"go/ast"
 	"go/token"
 	"go/types"
+	"sort"
 
 	"golang.org/x/tools/go/ast/astutil"
 	"golang.org/x/tools/internal/span"
@@ -197,3 +198,58 @@ func sameObj(x, y types.Object) bool {
 	}
 	return false
 }
+
+// ObjectReferences returns the locations of the identifiers across the
+// cache that refer to obj, and of its declaration if includeDeclaration is
+// set, sorted by file and offset. Only the declaring package of obj and the
+// packages importing it, directly or transitively, are searched.
+func ObjectReferences(c ICache, fset *token.FileSet, obj types.Object, includeDeclaration bool) []Location {
+	var candidates map[string]bool
+	if obj.Pkg() != nil {
+		// Promoted fields and methods may be selected by packages that only
+		// import the declaring package indirectly.
+		candidates = make(map[string]bool)
+		for _, path := range AffectedPackages(c, obj.Pkg().Path()) {
+			candidates[path] = true
+		}
+	}
+
+	var refs []*ast.Ident
+	seen := make(map[token.Pos]bool)
+	add := func(id *ast.Ident) {
+		// The same files may be cached in several packages, such as a
+		// package and its test variant.
+		if !seen[id.Pos()] {
+			seen[id.Pos()] = true
+			refs = append(refs, id)
+		}
+	}
+	walkVariants(c, func(p Package) bool {
+		info := p.GetTypesInfo()
+		if info == nil || p.GetTypes() == nil || candidates != nil && !candidates[p.GetTypes().Path()] {
+			return false
+		}
+		for id, use := range info.Uses {
+			if sameObj(obj, use) {
+				add(id)
+			}
+		}
+		if includeDeclaration {
+			for id, def := range info.Defs {
+				if def != nil && sameObj(obj, def) {
+					add(id)
+				}
+			}
+		}
+		return false
+	})
+
+	sort.Slice(refs, func(i, j int) bool {
+		pi, pj := fset.Position(refs[i].Pos()), fset.Position(refs[j].Pos())
+		if pi.Filename != pj.Filename {
+			return pi.Filename < pj.Filename
+		}
+		return pi.Offset < pj.Offset
+	})
+	return refStreamAndCollect(fset, refs, 0)
+}
@@ -0,0 +1,73 @@
+package source
+
+import (
+	"fmt"
+	"go/types"
+	"strings"
+	"testing"
+)
+
+// indexedCache is a testCache with a reverse import index.
+type indexedCache struct {
+	testCache
+	importers map[string][]string
+}
+
+func (c indexedCache) Importers(pkgPath string) []string { return c.importers[pkgPath] }
+
+func TestObjectReferences(t *testing.T) {
+	fset, pkgs := loadTestPackages(t,
+		testSource{path: "lib", files: map[string]string{"lib.go": `package lib
+
+type Store struct{ Size int }
+
+func (s *Store) Grow() { s.Size++ }
+
+func New() *Store { return &Store{Size: 1} }
+`}},
+		testSource{path: "app", files: map[string]string{"app.go": `package app
+
+import "lib"
+
+var store = lib.New()
+
+func run() int {
+	store.Grow()
+	return store.Size
+}
+`}},
+		testSource{path: "other", files: map[string]string{"other.go": `package other
+
+type Store struct{ Size int }
+
+var s = Store{Size: 2}
+`}},
+	)
+	lib := pkgs[0]
+	size := lib.types.Scope().Lookup("Store").Type().Underlying().(*types.Struct).Field(0)
+
+	refs := func(c ICache, includeDeclaration bool) string {
+		var texts []string
+		for _, loc := range ObjectReferences(c, fset, size, includeDeclaration) {
+			texts = append(texts, fmt.Sprintf("%s:%d", loc.Span.URI().Filename(), loc.Span.Start().Line()))
+			if text := testLocationText(t, pkgs, loc); text != "Size" {
+				t.Errorf("reference spans %q, want Size", text)
+			}
+		}
+		return strings.Join(texts, " ")
+	}
+
+	all := testCache{pkgs[0], pkgs[1], pkgs[2]}
+	if got, want := refs(all, false), "/src/app/app.go:9 /src/lib/lib.go:5 /src/lib/lib.go:7"; got != want {
+		t.Errorf("got references %s, want %s", got, want)
+	}
+	if got, want := refs(all, true), "/src/app/app.go:9 /src/lib/lib.go:3 /src/lib/lib.go:5 /src/lib/lib.go:7"; got != want {
+		t.Errorf("got references with declaration %s, want %s", got, want)
+	}
+
+	// Packages that the index does not list as importers are not searched.
+	indexed := indexedCache{testCache: all, importers: map[string][]string{}}
+	if got, want := refs(indexed, false), "/src/lib/lib.go:5 /src/lib/lib.go:7"; got != want {
+		t.Errorf("got references through an empty index %s, want %s", got, want)
+	}
+}
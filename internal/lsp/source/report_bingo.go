@@ -0,0 +1,858 @@
+package source
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// InterfaceImplementorMatrix maps each exported interface of the package
+// pkgPath to the locations of the named types across the cache that
+// implement it, either directly or through a pointer receiver.
+func InterfaceImplementorMatrix(c ICache, fset *token.FileSet, pkgPath string) map[string][]Location {
+	pkg := lookupPackage(c, pkgPath)
+	if pkg == nil {
+		return nil
+	}
+
+	var ifaces []*types.TypeName
+	scope := pkg.GetTypes().Scope()
+	for _, name := range scope.Names() {
+		obj, ok := scope.Lookup(name).(*types.TypeName)
+		if !ok || !obj.Exported() || isAlias(obj) {
+			continue
+		}
+		if iface, ok := obj.Type().Underlying().(*types.Interface); ok && iface.NumMethods() > 0 {
+			ifaces = append(ifaces, obj)
+		}
+	}
+	if len(ifaces) == 0 {
+		return nil
+	}
+
+	var allNamed []*types.Named
+	c.Walk(func(p Package) bool {
+		if p.GetTypesInfo() == nil {
+			return false
+		}
+		for _, obj := range p.GetTypesInfo().Defs {
+			if obj, ok := obj.(*types.TypeName); ok && !isAlias(obj) {
+				if named, ok := obj.Type().(*types.Named); ok && !isInterface(named) {
+					allNamed = append(allNamed, named)
+				}
+			}
+		}
+		return false
+	})
+	sort.Slice(allNamed, func(i, j int) bool {
+		return allNamed[i].String() < allNamed[j].String()
+	})
+
+	matrix := make(map[string][]Location, len(ifaces))
+	for _, iface := range ifaces {
+		T := iface.Type()
+		locs := []Location{}
+		for _, U := range allNamed {
+			if types.AssignableTo(U, T) || types.AssignableTo(types.NewPointer(U), T) {
+				obj := U.Obj()
+				locs = append(locs, toLocation(fset, obj.Pos(), obj.Name()))
+			}
+		}
+		matrix[iface.Name()] = locs
+	}
+
+	return matrix
+}
+
+// AssertionInfo describes a compile-time assertion that a type implements
+// an interface, written as 'var _ Interface = (*Concrete)(nil)'.
+type AssertionInfo struct {
+	Location  Location
+	Interface types.Type
+	Concrete  types.Type
+}
+
+// ImplementationAssertions returns the compile-time implementation
+// assertions declared at package level in pkg.
+func ImplementationAssertions(pkg Package, fset *token.FileSet) []AssertionInfo {
+	info := pkg.GetTypesInfo()
+	var assertions []AssertionInfo
+	for _, f := range pkg.GetSyntax() {
+		for _, decl := range f.Decls {
+			decl, ok := decl.(*ast.GenDecl)
+			if !ok || decl.Tok != token.VAR {
+				continue
+			}
+			for _, spec := range decl.Specs {
+				spec := spec.(*ast.ValueSpec)
+				if spec.Type == nil || len(spec.Values) != len(spec.Names) {
+					continue
+				}
+				iface := info.TypeOf(spec.Type)
+				if iface == nil || !isInterface(iface) {
+					continue
+				}
+				for i, name := range spec.Names {
+					if name.Name != "_" {
+						continue
+					}
+					concrete := info.TypeOf(spec.Values[i])
+					if concrete == nil || isInterface(concrete) {
+						continue
+					}
+					assertions = append(assertions, AssertionInfo{
+						Location:  toLocation(fset, name.Pos(), name.Name),
+						Interface: iface,
+						Concrete:  concrete,
+					})
+				}
+			}
+		}
+	}
+
+	return assertions
+}
+
+// stringerType is the fmt.Stringer interface.
+var stringerType = types.NewInterfaceType([]*types.Func{
+	types.NewFunc(token.NoPos, nil, "String", types.NewSignature(nil, nil,
+		types.NewTuple(types.NewVar(token.NoPos, nil, "", types.Typ[types.String])), false)),
+}, nil).Complete()
+
+// MissingStringer returns the exported struct types of pkg that do not
+// implement fmt.Stringer, through either their value or pointer method set.
+func MissingStringer(pkg Package, fset *token.FileSet) []Symbol {
+	info := pkg.GetTypesInfo()
+	var symbols []Symbol
+	for _, f := range pkg.GetSyntax() {
+		q := qualifier(f, pkg.GetTypes(), info)
+		for _, decl := range f.Decls {
+			decl, ok := decl.(*ast.GenDecl)
+			if !ok || decl.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range decl.Specs {
+				spec := spec.(*ast.TypeSpec)
+				obj := info.Defs[spec.Name]
+				if obj == nil || !obj.Exported() {
+					continue
+				}
+				if _, ok := obj.Type().Underlying().(*types.Struct); !ok {
+					continue
+				}
+				if types.Implements(obj.Type(), stringerType) || types.Implements(types.NewPointer(obj.Type()), stringerType) {
+					continue
+				}
+				symbols = append(symbols, typeSymbol(info, spec, obj, fset, q))
+			}
+		}
+	}
+
+	return symbols
+}
+
+// UnreferencedExports returns the exported functions and methods declared
+// across the cache that are not referenced by any package in it. Test files
+// and main packages are not searched for declarations, but their references
+// count.
+//
+// This is a heuristic for finding dead code: exported functions may be
+// called from outside the workspace, and methods may be called dynamically
+// through an interface.
+func UnreferencedExports(c ICache, fset *token.FileSet) []Symbol {
+	type decl struct {
+		fn  *ast.FuncDecl
+		obj *types.Func
+		q   types.Qualifier
+	}
+	var decls []decl
+	// Functions are identified by position, since a test variant of a
+	// package declares its own objects for the files it shares with it.
+	used := make(map[token.Pos]bool)
+	declared := make(map[token.Pos]bool)
+	walkVariants(c, func(p Package) bool {
+		info := p.GetTypesInfo()
+		if info == nil || p.GetTypes() == nil {
+			return false
+		}
+		for _, obj := range info.Uses {
+			if fn, ok := obj.(*types.Func); ok {
+				used[fn.Origin().Pos()] = true
+			}
+		}
+		if p.GetTypes().Name() == "main" {
+			return false
+		}
+		for _, f := range p.GetSyntax() {
+			if tok := fset.File(f.Pos()); tok == nil || strings.HasSuffix(tok.Name(), "_test.go") {
+				continue
+			}
+			q := qualifier(f, p.GetTypes(), info)
+			for _, d := range f.Decls {
+				fn, ok := d.(*ast.FuncDecl)
+				if !ok || !fn.Name.IsExported() {
+					continue
+				}
+				if obj, ok := info.Defs[fn.Name].(*types.Func); ok && !declared[obj.Pos()] {
+					declared[obj.Pos()] = true
+					decls = append(decls, decl{fn, obj, q})
+				}
+			}
+		}
+		return false
+	})
+
+	var symbols []Symbol
+	for _, d := range decls {
+		if !used[d.obj.Pos()] {
+			symbols = append(symbols, funcSymbol(d.fn, d.obj, fset, d.q))
+		}
+	}
+
+	return symbols
+}
+
+// UninstantiatedTypes returns the exported struct types declared across the
+// cache of which no package in it creates a value: the type is never used in
+// a composite literal, passed to new, or given to a variable, field or
+// parameter. Test files and main packages are not searched for declarations,
+// but their uses count.
+//
+// This is a heuristic for finding dead types: values may be created outside
+// the workspace, through reflection, or by a function of another type.
+func UninstantiatedTypes(c ICache, fset *token.FileSet) []Symbol {
+	type decl struct {
+		spec *ast.TypeSpec
+		obj  *types.TypeName
+		info *types.Info
+		q    types.Qualifier
+	}
+	var decls []decl
+	instantiated := make(map[*types.TypeName]bool)
+	instantiate := func(t types.Type) {
+		if named, ok := types.Unalias(t).(*types.Named); ok {
+			instantiated[named.Origin().Obj()] = true
+		}
+	}
+	c.Walk(func(p Package) bool {
+		info := p.GetTypesInfo()
+		if info == nil || p.GetTypes() == nil {
+			return false
+		}
+		for _, obj := range info.Defs {
+			if v, ok := obj.(*types.Var); ok {
+				instantiate(v.Type())
+			}
+		}
+		for _, f := range p.GetSyntax() {
+			ast.Inspect(f, func(n ast.Node) bool {
+				switch n := n.(type) {
+				case *ast.CompositeLit:
+					// The type of an elided literal, as in []T{{}}, is recorded too.
+					instantiate(info.TypeOf(n))
+				case *ast.CallExpr:
+					if id, ok := astutil.Unparen(n.Fun).(*ast.Ident); ok && len(n.Args) == 1 {
+						if b, ok := info.Uses[id].(*types.Builtin); ok && b.Name() == "new" {
+							instantiate(info.TypeOf(n.Args[0]))
+						}
+					}
+				}
+				return true
+			})
+		}
+		if p.GetTypes().Name() == "main" {
+			return false
+		}
+		for _, f := range p.GetSyntax() {
+			if tok := fset.File(f.Pos()); tok == nil || strings.HasSuffix(tok.Name(), "_test.go") {
+				continue
+			}
+			q := qualifier(f, p.GetTypes(), info)
+			for _, d := range f.Decls {
+				d, ok := d.(*ast.GenDecl)
+				if !ok || d.Tok != token.TYPE {
+					continue
+				}
+				for _, spec := range d.Specs {
+					spec := spec.(*ast.TypeSpec)
+					obj, ok := info.Defs[spec.Name].(*types.TypeName)
+					if !ok || !obj.Exported() || obj.IsAlias() {
+						continue
+					}
+					if _, ok := obj.Type().Underlying().(*types.Struct); ok {
+						decls = append(decls, decl{spec, obj, info, q})
+					}
+				}
+			}
+		}
+		return false
+	})
+
+	var symbols []Symbol
+	for _, d := range decls {
+		if !instantiated[d.obj] {
+			symbols = append(symbols, typeSymbol(d.info, d.spec, d.obj, fset, d.q))
+		}
+	}
+
+	return symbols
+}
+
+// MethodInfo describes an exported method declared in a package.
+type MethodInfo struct {
+	Name     string
+	Location Location
+	// TypeLocation is the position of the receiver type's declaration.
+	TypeLocation Location
+	// Pointer reports whether the method has a pointer receiver.
+	Pointer bool
+}
+
+// MethodsByType groups the exported methods declared in pkg by the name of
+// their exported receiver type, in declaration order.
+func MethodsByType(pkg Package, fset *token.FileSet) map[string][]MethodInfo {
+	info := pkg.GetTypesInfo()
+	methods := make(map[string][]MethodInfo)
+	for _, f := range pkg.GetSyntax() {
+		for _, decl := range f.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv == nil || len(fn.Recv.List) != 1 || !fn.Name.IsExported() {
+				continue
+			}
+			id := receiverTypeIdent(fn.Recv.List[0].Type)
+			if id == nil {
+				continue
+			}
+			tn, ok := info.Uses[id].(*types.TypeName)
+			if !ok || !tn.Exported() {
+				continue
+			}
+			_, pointer := info.TypeOf(fn.Recv.List[0].Type).(*types.Pointer)
+			methods[tn.Name()] = append(methods[tn.Name()], MethodInfo{
+				Name:         fn.Name.Name,
+				Location:     toLocation(fset, fn.Name.Pos(), fn.Name.Name),
+				TypeLocation: toLocation(fset, tn.Pos(), tn.Name()),
+				Pointer:      pointer,
+			})
+		}
+	}
+
+	return methods
+}
+
+// CouplingMetrics measures how a package is tied to the rest of the
+// workspace.
+type CouplingMetrics struct {
+	// Afferent is the number of packages in the cache that import the
+	// package.
+	Afferent int
+	// Efferent is the number of packages the package imports.
+	Efferent int
+}
+
+// PackageCoupling returns the coupling metrics of every package in the
+// cache, keyed by package path.
+func PackageCoupling(c ICache) map[string]CouplingMetrics {
+	metrics := make(map[string]CouplingMetrics)
+	var pkgs []*types.Package
+	c.Walk(func(p Package) bool {
+		if p.GetTypes() != nil {
+			pkgs = append(pkgs, p.GetTypes())
+			metrics[p.GetTypes().Path()] = CouplingMetrics{}
+		}
+		return false
+	})
+
+	for _, p := range pkgs {
+		m := metrics[p.Path()]
+		m.Efferent = len(p.Imports())
+		metrics[p.Path()] = m
+		for _, imp := range p.Imports() {
+			if m, ok := metrics[imp.Path()]; ok {
+				m.Afferent++
+				metrics[imp.Path()] = m
+			}
+		}
+	}
+
+	return metrics
+}
+
+// DeprecatedUsages returns the locations of every reference across the
+// cache to a symbol whose documentation has a paragraph starting with
+// "Deprecated: ". Only symbols declared in packages of the cache are
+// considered.
+func DeprecatedUsages(c ICache, fset *token.FileSet) []Location {
+	deprecated := make(map[types.Object]bool)
+	c.Walk(func(p Package) bool {
+		info := p.GetTypesInfo()
+		if info == nil {
+			return false
+		}
+		mark := func(doc *ast.CommentGroup, ids ...*ast.Ident) {
+			if !isDeprecated(doc) {
+				return
+			}
+			for _, id := range ids {
+				if obj := info.Defs[id]; obj != nil {
+					deprecated[obj] = true
+				}
+			}
+		}
+		for _, f := range p.GetSyntax() {
+			ast.Inspect(f, func(n ast.Node) bool {
+				switch n := n.(type) {
+				case *ast.FuncDecl:
+					mark(n.Doc, n.Name)
+				case *ast.GenDecl:
+					for _, spec := range n.Specs {
+						switch spec := spec.(type) {
+						case *ast.TypeSpec:
+							mark(spec.Doc, spec.Name)
+							if len(n.Specs) == 1 {
+								mark(n.Doc, spec.Name)
+							}
+						case *ast.ValueSpec:
+							mark(spec.Doc, spec.Names...)
+							if len(n.Specs) == 1 {
+								mark(n.Doc, spec.Names...)
+							}
+						}
+					}
+				case *ast.Field:
+					mark(n.Doc, n.Names...)
+				}
+				return true
+			})
+		}
+		return false
+	})
+	if len(deprecated) == 0 {
+		return nil
+	}
+
+	var locs []Location
+	c.Walk(func(p Package) bool {
+		info := p.GetTypesInfo()
+		if info == nil {
+			return false
+		}
+		for _, f := range p.GetSyntax() {
+			ast.Inspect(f, func(n ast.Node) bool {
+				id, ok := n.(*ast.Ident)
+				if !ok {
+					return true
+				}
+				obj := info.Uses[id]
+				if fn, ok := obj.(*types.Func); ok {
+					obj = fn.Origin()
+				}
+				if obj != nil && deprecated[obj] {
+					locs = append(locs, toLocation(fset, id.Pos(), id.Name))
+				}
+				return true
+			})
+		}
+		return false
+	})
+
+	return locs
+}
+
+// isDeprecated reports whether doc has a paragraph starting with
+// "Deprecated: ".
+func isDeprecated(doc *ast.CommentGroup) bool {
+	for _, para := range strings.Split(doc.Text(), "\n\n") {
+		if strings.HasPrefix(para, "Deprecated: ") {
+			return true
+		}
+	}
+	return false
+}
+
+// Graph is the import graph of the packages in a cache.
+type Graph struct {
+	// Nodes are the package paths, sorted.
+	Nodes []string `json:"nodes"`
+	// Edges are the imports between those packages, sorted.
+	Edges []Edge `json:"edges"`
+}
+
+// Edge records that package From imports package To.
+type Edge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// ImportGraph returns the graph of imports between the packages in the
+// cache. Imports of packages outside the cache, such as the standard
+// library, are omitted.
+func ImportGraph(c ICache) *Graph {
+	g := &Graph{}
+	var pkgs []*types.Package
+	inCache := make(map[string]bool)
+	c.Walk(func(p Package) bool {
+		if p.GetTypes() != nil {
+			pkgs = append(pkgs, p.GetTypes())
+			inCache[p.GetTypes().Path()] = true
+			g.Nodes = append(g.Nodes, p.GetTypes().Path())
+		}
+		return false
+	})
+
+	for _, p := range pkgs {
+		for _, imp := range p.Imports() {
+			if inCache[imp.Path()] {
+				g.Edges = append(g.Edges, Edge{From: p.Path(), To: imp.Path()})
+			}
+		}
+	}
+
+	sort.Strings(g.Nodes)
+	sort.Slice(g.Edges, func(i, j int) bool {
+		if g.Edges[i].From != g.Edges[j].From {
+			return g.Edges[i].From < g.Edges[j].From
+		}
+		return g.Edges[i].To < g.Edges[j].To
+	})
+	return g
+}
+
+// ToDOT renders the graph in the Graphviz DOT language.
+func (g *Graph) ToDOT() string {
+	var b strings.Builder
+	b.WriteString("digraph imports {\n")
+	for _, n := range g.Nodes {
+		fmt.Fprintf(&b, "\t%q;\n", n)
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(&b, "\t%q -> %q;\n", e.From, e.To)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// ConstInfo describes an exported package-level constant.
+type ConstInfo struct {
+	Name     string
+	Type     types.Type
+	Value    constant.Value
+	Location Location
+	// Group numbers the const blocks using iota, starting at 1, so that
+	// enum-like constants share a group. It is 0 for other constants.
+	Group int
+}
+
+// ExportedConstants returns the exported package-level constants of pkg in
+// declaration order.
+func ExportedConstants(pkg Package, fset *token.FileSet) []ConstInfo {
+	info := pkg.GetTypesInfo()
+	var consts []ConstInfo
+	groups := 0
+	for _, f := range pkg.GetSyntax() {
+		for _, decl := range f.Decls {
+			decl, ok := decl.(*ast.GenDecl)
+			if !ok || decl.Tok != token.CONST {
+				continue
+			}
+			group := 0
+			if usesIota(info, decl) {
+				groups++
+				group = groups
+			}
+			for _, spec := range decl.Specs {
+				for _, name := range spec.(*ast.ValueSpec).Names {
+					c, ok := info.Defs[name].(*types.Const)
+					if !ok || !c.Exported() {
+						continue
+					}
+					consts = append(consts, ConstInfo{
+						Name:     c.Name(),
+						Type:     c.Type(),
+						Value:    c.Val(),
+						Location: toLocation(fset, name.Pos(), name.Name),
+						Group:    group,
+					})
+				}
+			}
+		}
+	}
+
+	return consts
+}
+
+// usesIota reports whether a value of the const declaration decl refers to
+// iota.
+func usesIota(info *types.Info, decl *ast.GenDecl) bool {
+	found := false
+	ast.Inspect(decl, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && info.Uses[id] == types.Universe.Lookup("iota") {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// AffectedPackages returns the sorted paths of the packages of the cache
+// that a change to the package changedPath may affect: the package itself
+// and every package that imports it, directly or transitively.
+func AffectedPackages(c ICache, changedPath string) []string {
+	importers := importersFunc(c)
+	seen := map[string]bool{changedPath: true}
+	affected := []string{changedPath}
+	for i := 0; i < len(affected); i++ {
+		for _, importer := range importers(affected[i]) {
+			if !seen[importer] {
+				seen[importer] = true
+				affected = append(affected, importer)
+			}
+		}
+	}
+
+	sort.Strings(affected)
+	return affected
+}
+
+// importerIndex is implemented by caches that maintain a reverse import
+// index.
+type importerIndex interface {
+	Importers(pkgPath string) []string
+}
+
+// importersFunc returns a function listing the paths of the packages of c
+// that directly import a package, using the reverse import index of c if it
+// has one, or else an index built by walking c once.
+func importersFunc(c ICache) func(pkgPath string) []string {
+	if index, ok := c.(importerIndex); ok {
+		return index.Importers
+	}
+
+	importers := make(map[string][]string)
+	c.Walk(func(p Package) bool {
+		if p.GetTypes() != nil {
+			for _, imp := range p.GetTypes().Imports() {
+				importers[imp.Path()] = append(importers[imp.Path()], p.GetTypes().Path())
+			}
+		}
+		return false
+	})
+	return func(pkgPath string) []string { return importers[pkgPath] }
+}
+
+// ErrorWrapSites returns the locations across the cache of the error values
+// of type errType that are wrapped, either by fmt.Errorf with a %w verb or
+// by a Wrap-style function of a known errors package, such as
+// errors.Wrap(err, msg) of github.com/pkg/errors.
+func ErrorWrapSites(c ICache, fset *token.FileSet, errType types.Type) []Location {
+	var locs []Location
+	c.Walk(func(p Package) bool {
+		info := p.GetTypesInfo()
+		if info == nil {
+			return false
+		}
+		for _, f := range p.GetSyntax() {
+			ast.Inspect(f, func(n ast.Node) bool {
+				call, ok := n.(*ast.CallExpr)
+				if !ok {
+					return true
+				}
+				for _, arg := range wrappedArgs(info, call) {
+					if typ := info.TypeOf(arg); typ != nil && types.Identical(typ, errType) {
+						locs = append(locs, toLocation(fset, arg.Pos(), types.ExprString(arg)))
+					}
+				}
+				return true
+			})
+		}
+		return false
+	})
+
+	return locs
+}
+
+// wrapPackages are the paths of the errors packages whose Wrap-style
+// functions wrap their first argument.
+var wrapPackages = map[string]bool{
+	"github.com/pkg/errors":         true,
+	"github.com/cockroachdb/errors": true,
+}
+
+// wrappedArgs returns the arguments of call that it wraps as errors.
+func wrappedArgs(info *types.Info, call *ast.CallExpr) []ast.Expr {
+	var id *ast.Ident
+	switch fun := call.Fun.(type) {
+	case *ast.Ident:
+		id = fun
+	case *ast.SelectorExpr:
+		id = fun.Sel
+	}
+	fn, ok := info.Uses[id].(*types.Func)
+	if !ok || fn.Pkg() == nil || len(call.Args) == 0 {
+		return nil
+	}
+
+	switch {
+	case fn.Pkg().Path() == "fmt" && fn.Name() == "Errorf":
+		tv := info.Types[call.Args[0]]
+		if tv.Value == nil || tv.Value.Kind() != constant.String {
+			return nil
+		}
+		verbs, ok := parsePrintfVerbs(constant.StringVal(tv.Value))
+		if !ok {
+			return nil
+		}
+		var args []ast.Expr
+		for i, verb := range verbs {
+			if verb == 'w' && i+1 < len(call.Args) {
+				args = append(args, call.Args[i+1])
+			}
+		}
+		return args
+	case wrapPackages[fn.Pkg().Path()]:
+		switch fn.Name() {
+		case "Wrap", "Wrapf", "WithMessage", "WithMessagef", "WithStack":
+			return call.Args[:1]
+		}
+	}
+	return nil
+}
+
+// TestCoverageGaps returns the exported functions of the package pkgPath
+// that have no test, that is no function TestFoo or TestFoo_suffix for a
+// function Foo, in the _test.go files of the package or of its external
+// test package found in the cache. This is a heuristic: functions may be
+// tested under other names.
+func TestCoverageGaps(c ICache, fset *token.FileSet, pkgPath string) []Symbol {
+	type decl struct {
+		fn  *ast.FuncDecl
+		obj types.Object
+		q   types.Qualifier
+	}
+	var funcs []decl
+	seen := make(map[string]bool)
+	tests := make(map[string]bool)
+	walkVariants(c, func(p Package) bool {
+		if p.GetTypes() == nil || p.PkgPath() != pkgPath && p.PkgPath() != pkgPath+"_test" {
+			return false
+		}
+		info := p.GetTypesInfo()
+		for _, f := range p.GetSyntax() {
+			tok := fset.File(f.Pos())
+			isTest := tok != nil && strings.HasSuffix(tok.Name(), "_test.go")
+			for _, d := range f.Decls {
+				fn, ok := d.(*ast.FuncDecl)
+				if !ok || fn.Recv != nil {
+					continue
+				}
+				name := fn.Name.Name
+				switch {
+				case isTest:
+					if strings.HasPrefix(name, "Test") {
+						tests[name] = true
+					}
+				case fn.Name.IsExported() && !seen[name] && p.PkgPath() == pkgPath:
+					// Test variants repeat the files of the package.
+					seen[name] = true
+					if obj := info.Defs[fn.Name]; obj != nil {
+						funcs = append(funcs, decl{fn, obj, qualifier(f, p.GetTypes(), info)})
+					}
+				}
+			}
+		}
+		return false
+	})
+
+	var gaps []Symbol
+	for _, d := range funcs {
+		if !hasTest(tests, d.fn.Name.Name) {
+			gaps = append(gaps, funcSymbol(d.fn, d.obj, fset, d.q))
+		}
+	}
+	return gaps
+}
+
+// hasTest reports whether tests holds TestName or TestName_suffix.
+func hasTest(tests map[string]bool, name string) bool {
+	if tests["Test"+name] {
+		return true
+	}
+	for test := range tests {
+		if strings.HasPrefix(test, "Test"+name+"_") {
+			return true
+		}
+	}
+	return false
+}
+
+// FunctionsMatchingSignature returns the locations of the package-level
+// functions across the cache that are assignable to sig, such as every
+// func(http.ResponseWriter, *http.Request) handler. Methods are not
+// considered.
+func FunctionsMatchingSignature(c ICache, fset *token.FileSet, sig *types.Signature) []Location {
+	var locs []Location
+	c.Walk(func(p Package) bool {
+		pkg := p.GetTypes()
+		if pkg == nil {
+			return false
+		}
+		scope := pkg.Scope()
+		for _, name := range scope.Names() {
+			fn, ok := scope.Lookup(name).(*types.Func)
+			if ok && types.AssignableTo(fn.Type(), sig) {
+				locs = append(locs, toLocation(fset, fn.Pos(), fn.Name()))
+			}
+		}
+		return false
+	})
+
+	return locs
+}
+
+// UnsafeUsage is a use of a symbol of the unsafe package.
+type UnsafeUsage struct {
+	// Symbol is the name of the unsafe symbol, such as "Pointer".
+	Symbol   string
+	Location Location
+}
+
+// UnsafeUsages returns the uses across the cache of the symbols of the
+// unsafe package, such as unsafe.Pointer and unsafe.Sizeof, found by their
+// package qualifier.
+func UnsafeUsages(c ICache, fset *token.FileSet) []UnsafeUsage {
+	var usages []UnsafeUsage
+	c.Walk(func(p Package) bool {
+		info := p.GetTypesInfo()
+		if info == nil {
+			return false
+		}
+		for _, f := range p.GetSyntax() {
+			ast.Inspect(f, func(n ast.Node) bool {
+				sel, ok := n.(*ast.SelectorExpr)
+				if !ok {
+					return true
+				}
+				id, ok := sel.X.(*ast.Ident)
+				if !ok {
+					return true
+				}
+				if pkgName, ok := info.Uses[id].(*types.PkgName); ok && pkgName.Imported().Path() == "unsafe" {
+					usages = append(usages, UnsafeUsage{
+						Symbol:   sel.Sel.Name,
+						Location: toLocation(fset, sel.Pos(), types.ExprString(sel)),
+					})
+				}
+				return true
+			})
+		}
+		return false
+	})
+
+	return usages
+}
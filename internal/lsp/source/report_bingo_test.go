@@ -0,0 +1,610 @@
+package source
+
+import (
+	"fmt"
+	"go/types"
+	"strings"
+	"testing"
+)
+
+func TestInterfaceImplementorMatrix(t *testing.T) {
+	fset, pkgs := loadTestPackages(t,
+		testSource{path: "shapes", files: map[string]string{"shapes.go": `package shapes
+
+type Shape interface{ Area() float64 }
+
+type Namer interface{ Name() string }
+
+type notExported interface{ hidden() }
+`}},
+		testSource{path: "impl", files: map[string]string{"impl.go": `package impl
+
+type Square struct{ side float64 }
+
+func (s Square) Area() float64 { return s.side * s.side }
+
+type Person struct{}
+
+func (p *Person) Name() string { return "" }
+`}},
+	)
+
+	matrix := InterfaceImplementorMatrix(testCache{pkgs[0], pkgs[1]}, fset, "shapes")
+	if len(matrix) != 2 {
+		t.Fatalf("got %d interfaces, want 2: %v", len(matrix), matrix)
+	}
+	for iface, want := range map[string]string{"Shape": "Square", "Namer": "Person"} {
+		locs := matrix[iface]
+		if len(locs) != 1 {
+			t.Fatalf("%s: got %d implementers, want 1", iface, len(locs))
+		}
+		if got := testLocationText(t, pkgs, locs[0]); got != want {
+			t.Errorf("%s: got implementer %s, want %s", iface, got, want)
+		}
+	}
+}
+
+func TestImplementationAssertions(t *testing.T) {
+	fset, pkgs := loadTestPackages(t, testSource{path: "assert", files: map[string]string{"assert.go": `package assert
+
+import "io"
+
+type nopReader struct{}
+
+func (*nopReader) Read(p []byte) (int, error) { return 0, io.EOF }
+
+var _ io.Reader = (*nopReader)(nil)
+
+var r io.Reader = (*nopReader)(nil)
+`}})
+
+	got := ImplementationAssertions(pkgs[0], fset)
+	if len(got) != 1 {
+		t.Fatalf("got %d assertions, want 1", len(got))
+	}
+	if s := got[0].Interface.String(); s != "io.Reader" {
+		t.Errorf("got interface %s, want io.Reader", s)
+	}
+	if s := got[0].Concrete.String(); s != "*assert.nopReader" {
+		t.Errorf("got concrete type %s, want *assert.nopReader", s)
+	}
+	if line := got[0].Location.Span.Start().Line(); line != 9 {
+		t.Errorf("got assertion on line %d, want 9", line)
+	}
+}
+
+func TestMissingStringer(t *testing.T) {
+	fset, pkgs := loadTestPackages(t, testSource{path: "stringer", files: map[string]string{"stringer.go": `package stringer
+
+type Point struct{ X, Y int }
+
+func (p *Point) String() string { return "" }
+
+type Size struct{ W, H int }
+
+type unexported struct{}
+
+type Name string
+`}})
+
+	got := MissingStringer(pkgs[0], fset)
+	if len(got) != 1 || got[0].Name != "Size" {
+		t.Errorf("got %v, want only Size", got)
+	}
+}
+
+func TestUnreferencedExports(t *testing.T) {
+	fset, pkgs := loadTestPackages(t,
+		testSource{path: "lib", files: map[string]string{"lib.go": `package lib
+
+func Used() {}
+
+func Unused() {}
+
+func unexported() {}
+
+type T struct{}
+
+func (T) Method() {}
+`}},
+		testSource{path: "app", files: map[string]string{"app.go": `package main
+
+import "lib"
+
+func Main() {
+	lib.Used()
+	lib.T{}.Method()
+}
+`}},
+	)
+
+	var got []string
+	for _, s := range UnreferencedExports(testCache{pkgs[0], pkgs[1]}, fset) {
+		got = append(got, s.Name)
+	}
+	if len(got) != 1 || got[0] != "Unused" {
+		t.Errorf("got unreferenced exports %v, want [Unused]", got)
+	}
+}
+
+func TestUninstantiatedTypes(t *testing.T) {
+	fset, pkgs := loadTestPackages(t,
+		testSource{path: "lib", files: map[string]string{"lib.go": `package lib
+
+type Literal struct{}
+
+type Elided struct{}
+
+type Allocated struct{}
+
+type Declared struct{}
+
+type Field struct{}
+
+type Unused struct{}
+
+type PointerOnly struct{}
+
+type Name string
+
+type hidden struct{}
+`}},
+		testSource{path: "app", files: map[string]string{"app.go": `package main
+
+import "lib"
+
+type holder struct {
+	f lib.Field
+}
+
+func Main(p *lib.PointerOnly) {
+	_ = &lib.Literal{}
+	_ = []lib.Elided{{}}
+	_ = new(lib.Allocated)
+	var d lib.Declared
+	_, _ = d, holder{}
+}
+`}},
+	)
+
+	var got []string
+	for _, s := range UninstantiatedTypes(testCache{pkgs[0], pkgs[1]}, fset) {
+		got = append(got, s.Name)
+	}
+	if want := "Unused PointerOnly"; strings.Join(got, " ") != want {
+		t.Errorf("got uninstantiated types %v, want %s", got, want)
+	}
+}
+
+func TestMethodsByType(t *testing.T) {
+	fset, pkgs := loadTestPackages(t, testSource{path: "shapes", files: map[string]string{
+		"circle.go": `package shapes
+
+type Circle struct{ r float64 }
+
+func (c Circle) Area() float64 { return 3 * c.r * c.r }
+
+func (c *Circle) Scale(f float64) { c.r *= f }
+
+func (c Circle) radius() float64 { return c.r }
+`,
+		"square.go": `package shapes
+
+type Square struct{ side float64 }
+
+func (s Square) Area() float64 { return s.side * s.side }
+
+func (s Square) Perimeter() float64 { return 4 * s.side }
+
+func (s *Square) Scale(f float64) { s.side *= f }
+
+type hidden struct{}
+
+func (hidden) Visible() {}
+`,
+	}})
+
+	got := MethodsByType(pkgs[0], fset)
+	want := map[string]string{
+		"Circle": "Area Scale*",
+		"Square": "Area Perimeter Scale*",
+	}
+	if len(got) != len(want) {
+		t.Errorf("got methods for %d types, want %d: %v", len(got), len(want), got)
+	}
+	for typ, names := range want {
+		var s []string
+		for _, m := range got[typ] {
+			name := m.Name
+			if m.Pointer {
+				name += "*"
+			}
+			s = append(s, name)
+			if text := testLocationText(t, pkgs, m.TypeLocation); text != typ {
+				t.Errorf("%s.%s: type location has text %q", typ, m.Name, text)
+			}
+			if text := testLocationText(t, pkgs, m.Location); text != m.Name {
+				t.Errorf("%s.%s: location has text %q", typ, m.Name, text)
+			}
+		}
+		if strings.Join(s, " ") != names {
+			t.Errorf("%s: got methods %v, want %s", typ, s, names)
+		}
+	}
+}
+
+func TestPackageCoupling(t *testing.T) {
+	_, pkgs := loadTestPackages(t,
+		testSource{path: "base", files: map[string]string{"base.go": `package base
+
+const Name = "base"
+`}},
+		testSource{path: "mid", files: map[string]string{"mid.go": `package mid
+
+import "base"
+
+const Name = base.Name + "/mid"
+`}},
+		testSource{path: "top", files: map[string]string{"top.go": `package top
+
+import (
+	"base"
+	"mid"
+	"strings"
+)
+
+var Name = strings.ToUpper(base.Name + mid.Name)
+`}},
+	)
+	got := PackageCoupling(testCache{pkgs[0], pkgs[1], pkgs[2]})
+	want := map[string]CouplingMetrics{
+		"base": {Afferent: 2, Efferent: 0},
+		"mid":  {Afferent: 1, Efferent: 1},
+		"top":  {Afferent: 0, Efferent: 3},
+	}
+	if len(got) != len(want) {
+		t.Errorf("got metrics for %d packages, want %d: %v", len(got), len(want), got)
+	}
+	for path, w := range want {
+		if got[path] != w {
+			t.Errorf("%s: got %+v, want %+v", path, got[path], w)
+		}
+	}
+}
+
+func TestDeprecatedUsages(t *testing.T) {
+	fset, pkgs := loadTestPackages(t,
+		testSource{path: "old", files: map[string]string{"old.go": `package old
+
+// Fetch fetches.
+//
+// Deprecated: use FetchContext instead.
+func Fetch() {}
+
+func FetchContext() {}
+
+// Mentions Deprecated: in passing.
+func Current() {}
+`}},
+		testSource{path: "app", files: map[string]string{"app.go": `package app
+
+import "old"
+
+func run() {
+	old.Fetch()
+	old.FetchContext()
+	old.Current()
+}
+
+func retry() {
+	old.Fetch()
+}
+`}},
+	)
+
+	locs := DeprecatedUsages(testCache{pkgs[0], pkgs[1]}, fset)
+	if len(locs) != 2 {
+		t.Fatalf("got %d deprecated usages, want 2: %v", len(locs), locs)
+	}
+	for _, loc := range locs {
+		if got := testLocationText(t, pkgs, loc); got != "Fetch" {
+			t.Errorf("got usage %q, want Fetch", got)
+		}
+		if loc.Span.URI().Filename() != pkgs[1].filenames[0] {
+			t.Errorf("got usage in %s, want app.go", loc.Span.URI())
+		}
+	}
+	if locs[0].Span.Start().Line() == locs[1].Span.Start().Line() {
+		t.Errorf("got both usages on line %d", locs[0].Span.Start().Line())
+	}
+}
+
+func TestImportGraph(t *testing.T) {
+	_, pkgs := loadTestPackages(t,
+		testSource{path: "base", files: map[string]string{"base.go": `package base
+
+const Name = "base"
+`}},
+		testSource{path: "mid", files: map[string]string{"mid.go": `package mid
+
+import (
+	"base"
+	"strings"
+)
+
+var Name = strings.ToUpper(base.Name)
+`}},
+		testSource{path: "top", files: map[string]string{"top.go": `package top
+
+import (
+	"base"
+	"mid"
+)
+
+var Name = base.Name + mid.Name
+`}},
+	)
+
+	g := ImportGraph(testCache{pkgs[2], pkgs[0], pkgs[1]})
+	want := `digraph imports {
+	"base";
+	"mid";
+	"top";
+	"mid" -> "base";
+	"top" -> "base";
+	"top" -> "mid";
+}
+`
+	if got := g.ToDOT(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestExportedConstants(t *testing.T) {
+	fset, pkgs := loadTestPackages(t, testSource{path: "consts", files: map[string]string{"consts.go": `package consts
+
+type Color int
+
+const (
+	Red Color = iota
+	Green
+	hidden
+	Blue
+)
+
+const MaxSize = 1 << 10
+
+const (
+	KB = 1 << (10 * (iota + 1))
+	MB
+)
+
+const Name, Version = "consts", "1.0"
+`}})
+
+	var got []string
+	for _, c := range ExportedConstants(pkgs[0], fset) {
+		got = append(got, fmt.Sprintf("%d %s %s %s", c.Group, c.Name, c.Type, c.Value))
+		if text := testLocationText(t, pkgs, c.Location); text != c.Name {
+			t.Errorf("%s: location has text %q", c.Name, text)
+		}
+	}
+	want := []string{
+		"1 Red consts.Color 0",
+		"1 Green consts.Color 1",
+		"1 Blue consts.Color 3",
+		"0 MaxSize untyped int 1024",
+		"2 KB untyped int 1024",
+		"2 MB untyped int 1048576",
+		`0 Name untyped string "consts"`,
+		`0 Version untyped string "1.0"`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got constants\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestAffectedPackages(t *testing.T) {
+	_, pkgs := loadTestPackages(t,
+		testSource{path: "leaf", files: map[string]string{"leaf.go": `package leaf
+
+const V = 1
+`}},
+		testSource{path: "left", files: map[string]string{"left.go": `package left
+
+import "leaf"
+
+const V = leaf.V
+`}},
+		testSource{path: "right", files: map[string]string{"right.go": `package right
+
+import "leaf"
+
+const V = leaf.V
+`}},
+		testSource{path: "root", files: map[string]string{"root.go": `package root
+
+import (
+	"left"
+	"right"
+)
+
+const V = left.V + right.V
+`}},
+		testSource{path: "other", files: map[string]string{"other.go": `package other
+
+const V = 2
+`}},
+	)
+	c := testCache{pkgs[0], pkgs[1], pkgs[2], pkgs[3], pkgs[4]}
+
+	for changed, want := range map[string]string{
+		"leaf":  "leaf left right root",
+		"left":  "left root",
+		"root":  "root",
+		"other": "other",
+	} {
+		if got := strings.Join(AffectedPackages(c, changed), " "); got != want {
+			t.Errorf("%s: got affected packages %q, want %q", changed, got, want)
+		}
+	}
+}
+
+func TestErrorWrapSites(t *testing.T) {
+	fset, pkgs := loadTestPackages(t,
+		testSource{path: "github.com/pkg/errors", files: map[string]string{"errors.go": `package errors
+
+func Wrap(err error, msg string) error { return err }
+`}},
+		testSource{path: "example.com/myerrors", files: map[string]string{"errors.go": `package myerrors
+
+func Wrap(err error, msg string) error { return err }
+`}},
+		testSource{path: "store", files: map[string]string{"store.go": `package store
+
+import (
+	"fmt"
+
+	"example.com/myerrors"
+	"github.com/pkg/errors"
+)
+
+type NotFound struct{ Key string }
+
+func (e *NotFound) Error() string { return e.Key }
+
+func get(key string) error {
+	nf := &NotFound{key}
+	if key == "" {
+		return fmt.Errorf("get %s: %w", key, nf)
+	}
+	if key == "x" {
+		return fmt.Errorf("get %v", nf)
+	}
+	var other error
+	_ = fmt.Errorf("other: %w", other)
+	// Only known errors packages wrap.
+	_ = myerrors.Wrap(nf, "get")
+	return errors.Wrap(nf, "get")
+}
+`}},
+	)
+	store := pkgs[2]
+	notFound := types.NewPointer(store.types.Scope().Lookup("NotFound").Type())
+
+	locs := ErrorWrapSites(testCache{pkgs[0], pkgs[1], store}, fset, notFound)
+	if len(locs) != 2 {
+		t.Fatalf("got %d wrap sites, want 2: %v", len(locs), locs)
+	}
+	for i, want := range []int{17, 26} {
+		if got := locs[i].Span.Start().Line(); got != want || testLocationText(t, pkgs, locs[i]) != "nf" {
+			t.Errorf("got wrap site %q on line %d, want nf on line %d", testLocationText(t, pkgs, locs[i]), got, want)
+		}
+	}
+}
+
+func TestTestCoverageGaps(t *testing.T) {
+	fset, pkgs := loadTestPackages(t,
+		testSource{path: "calc", files: map[string]string{
+			"calc.go": `package calc
+
+func Add(a, b int) int { return a + b }
+
+func Sub(a, b int) int { return a - b }
+
+func Mul(a, b int) int { return a * b }
+
+func Div(a, b int) int { return a / b }
+
+func helper() {}
+`,
+			"calc_test.go": `package calc
+
+import "testing"
+
+func TestAdd(t *testing.T) {}
+
+func TestMul_overflow(t *testing.T) {}
+
+func TestSubtle(t *testing.T) {}
+`,
+		}},
+		testSource{path: "calc_test", dir: "/src/calc", files: map[string]string{"example_test.go": `package calc_test
+
+import "testing"
+
+func TestDiv(t *testing.T) {}
+`}},
+	)
+
+	var got []string
+	for _, s := range TestCoverageGaps(testCache{pkgs[0], pkgs[1]}, fset, "calc") {
+		got = append(got, s.Name)
+	}
+	if strings.Join(got, " ") != "Sub" {
+		t.Errorf("got untested functions %v, want [Sub]", got)
+	}
+}
+
+func TestFunctionsMatchingSignature(t *testing.T) {
+	fset, pkgs := loadTestPackages(t, testSource{path: "web", files: map[string]string{"web.go": `package web
+
+import "net/http"
+
+type server struct{}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {}
+
+func handleIndex(w http.ResponseWriter, r *http.Request) {}
+
+func handleLogin(rw http.ResponseWriter, req *http.Request) {}
+
+func handleValue(w http.ResponseWriter, r http.Request) {}
+
+func helper(w http.ResponseWriter) {}
+
+var handler = handleIndex
+`}})
+	p := pkgs[0]
+	sig := p.types.Scope().Lookup("handleIndex").Type().(*types.Signature)
+
+	var got []string
+	for _, loc := range FunctionsMatchingSignature(testCache{p}, fset, sig) {
+		got = append(got, testLocationText(t, pkgs, loc))
+	}
+	if want := "handleIndex handleLogin"; strings.Join(got, " ") != want {
+		t.Errorf("got functions %q, want %q", got, want)
+	}
+}
+
+func TestUnsafeUsages(t *testing.T) {
+	fset, pkgs := loadTestPackages(t,
+		testSource{path: "raw", files: map[string]string{"raw.go": `package raw
+
+import u "unsafe"
+
+type header struct{ data u.Pointer }
+
+func size(x int64) uintptr { return u.Sizeof(x) }
+
+func bytes(p *int64) *byte { return (*byte)(u.Pointer(p)) }
+`}},
+		testSource{path: "clean", files: map[string]string{"clean.go": `package clean
+
+type Pointer struct{}
+
+func size(p Pointer) int { return 0 }
+`}},
+	)
+
+	var got []string
+	for _, usage := range UnsafeUsages(testCache{pkgs[0], pkgs[1]}, fset) {
+		got = append(got, fmt.Sprintf("%s:%d:%s", usage.Symbol, usage.Location.Span.Start().Line(), testLocationText(t, pkgs, usage.Location)))
+	}
+	if want := "Pointer:5:u.Pointer Sizeof:7:u.Sizeof Pointer:9:u.Pointer"; strings.Join(got, " ") != want {
+		t.Errorf("got unsafe usages %q, want %q", got, want)
+	}
+	if usages := UnsafeUsages(testCache{pkgs[1]}, fset); len(usages) != 0 {
+		t.Errorf("got unsafe usages %v in a clean package", usages)
+	}
+}
@@ -0,0 +1,92 @@
+package source
+
+import (
+	"fmt"
+	"go/ast"
+	"go/doc"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// CallSignature returns the signature of the function called by the call
+// expression whose parentheses enclose pos, with the index of the parameter
+// the argument at pos is passed to. The arguments of a variadic call past
+// the last parameter are all passed to it. Unlike SignatureHelp, it needs
+// no view: built-in functions are described by the signature the type
+// checker recorded for the call.
+func CallSignature(pkg Package, fset *token.FileSet, pos token.Pos) (*SignatureInformation, error) {
+	path, _, err := astPathEnclosingInterval(pkg, fset, pos, pos)
+	if err != nil {
+		return nil, err
+	}
+
+	var call *ast.CallExpr
+FindCall:
+	for _, node := range path {
+		switch node := node.(type) {
+		case *ast.CallExpr:
+			if node.Lparen < pos && pos <= node.Rparen {
+				call = node
+				break FindCall
+			}
+		case *ast.FuncLit, *ast.FuncType:
+			// An argument may be a function literal, whose body is
+			// not part of the argument list.
+			return nil, fmt.Errorf("no signature help within a function declaration")
+		}
+	}
+	if call == nil {
+		return nil, fmt.Errorf("no call expression at %s", fset.Position(pos))
+	}
+
+	info := pkg.GetTypesInfo()
+	var sig *types.Signature
+	if t := info.TypeOf(call.Fun); t != nil {
+		sig, _ = t.Underlying().(*types.Signature)
+	}
+	if sig == nil {
+		return nil, fmt.Errorf("%s is not a function call", fset.Position(call.Pos()))
+	}
+
+	// There is no object for the call of a function value that is not
+	// named, as in f()().
+	var obj types.Object
+	fun := astutil.Unparen(call.Fun)
+	switch x := fun.(type) {
+	case *ast.IndexExpr:
+		fun = x.X
+	case *ast.IndexListExpr:
+		fun = x.X
+	}
+	switch fun := fun.(type) {
+	case *ast.Ident:
+		obj = info.ObjectOf(fun)
+	case *ast.SelectorExpr:
+		obj = info.ObjectOf(fun.Sel)
+	}
+
+	var q types.Qualifier
+	for _, f := range pkg.GetSyntax() {
+		if f.Pos() <= call.Pos() && call.End() <= f.End() {
+			q = qualifier(f, pkg.GetTypes(), info)
+			break
+		}
+	}
+	params := formatParams(sig.Params(), sig.Variadic(), q)
+	results, writeResultParens := formatResults(sig.Results(), q)
+	activeParam := activeParameter(call, sig.Params().Len(), sig.Variadic(), pos)
+
+	name := "func"
+	if obj != nil {
+		name = obj.Name()
+	}
+	help := signatureInformation(name, nil, params, results, writeResultParens, activeParam)
+	if obj != nil && obj.Pos().IsValid() {
+		if nodes, _, err := getObjectPathNode(pkg, fset, obj); err == nil {
+			help.Documentation = doc.Synopsis(PullComments(nodes))
+		}
+	}
+	return help, nil
+}
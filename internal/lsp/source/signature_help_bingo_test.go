@@ -0,0 +1,62 @@
+package source
+
+import (
+	"testing"
+)
+
+func TestCallSignature(t *testing.T) {
+	fset, pkgs := loadTestPackages(t, testSource{path: "calls", files: map[string]string{"calls.go": `package calls
+
+// Join joins the parts with sep.
+func Join(sep string, parts ...string) string { return "" }
+
+type Buffer struct{}
+
+// Write appends p.
+func (b *Buffer) Write(p []byte, n int, flush bool) error { return nil }
+
+func Make() func(int) bool { return nil }
+
+func use(b *Buffer, names []string) {
+	Join(",", "a", "b", "c")
+	Join(",", names...)
+	b.Write(nil, 1, true)
+	Make()(1)
+	_ = append(names, "d")
+}
+`}})
+	p := pkgs[0]
+
+	for _, test := range []struct {
+		marker, label string
+		active        int
+		doc           string
+	}{
+		{"Join(^\",\", \"a\"", "Join(sep string, parts ...string) string", 0, "Join joins the parts with sep."},
+		{"\"a\", ^\"b\"", "Join(sep string, parts ...string) string", 1, "Join joins the parts with sep."},
+		// Every argument past the last parameter is passed to it.
+		{"\"b\", \"^c\")", "Join(sep string, parts ...string) string", 1, "Join joins the parts with sep."},
+		{"Join(\",\", na^mes...)", "Join(sep string, parts ...string) string", 1, "Join joins the parts with sep."},
+		{"b.Write(nil, ^1", "Write(p []byte, n int, flush bool) error", 1, "Write appends p."},
+		// The cursor between two commas, on the blank before an argument.
+		{"b.Write(nil,^ 1", "Write(p []byte, n int, flush bool) error", 1, "Write appends p."},
+		{"b.Write(nil, 1,^ true)", "Write(p []byte, n int, flush bool) error", 2, "Write appends p."},
+		{"b.Write(nil, 1^, true)", "Write(p []byte, n int, flush bool) error", 1, "Write appends p."},
+		{"Make()(^1)", "func(int) bool", 0, ""},
+		// The signature recorded for a built-in call has no parameter names.
+		{"append(names, ^\"d\")", "append([]string, ...string) []string", 1, ""},
+	} {
+		help, err := CallSignature(p, fset, testPos(t, fset, p, "calls.go", test.marker))
+		if err != nil {
+			t.Errorf("%s: %v", test.marker, err)
+			continue
+		}
+		if help.Label != test.label || help.ActiveParameter != test.active || help.Documentation != test.doc {
+			t.Errorf("%s: got %q active %d doc %q, want %q active %d doc %q", test.marker, help.Label, help.ActiveParameter, help.Documentation, test.label, test.active, test.doc)
+		}
+	}
+
+	if _, err := CallSignature(p, fset, testPos(t, fset, p, "calls.go", "^Join(\",\", \"a\"")); err == nil {
+		t.Errorf("CallSignature outside the parentheses of a call succeeded")
+	}
+}
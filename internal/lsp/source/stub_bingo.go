@@ -0,0 +1,96 @@
+package source
+
+import (
+	"bytes"
+	"fmt"
+	"go/types"
+)
+
+// GenerateStub returns the Go source of the methods of iface that concrete
+// lacks, declared on receiver recvName of type concrete, which may be a
+// pointer to a named type, sorted by name. Each method body panics with "not implemented".
+// Types of other packages than that of concrete are qualified by package
+// name, so the importing file must import them.
+func GenerateStub(concrete types.Type, iface *types.Interface, recvName string) (string, error) {
+	named, ok := deref(concrete).(*types.Named)
+	if !ok {
+		return "", fmt.Errorf("cannot declare methods on unnamed type %s", concrete)
+	}
+	pkg := named.Obj().Pkg()
+	qf := func(p *types.Package) string {
+		if p == pkg {
+			return ""
+		}
+		return p.Name()
+	}
+
+	missing, err := missingMethods(concrete, iface)
+	if err != nil {
+		return "", err
+	}
+
+	var b bytes.Buffer
+	for i, m := range missing {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		sig := m.Type().(*types.Signature)
+		fmt.Fprintf(&b, "func (%s %s) %s(", recvName, types.TypeString(concrete, qf), m.Name())
+		params := sig.Params()
+		for j := 0; j < params.Len(); j++ {
+			if j > 0 {
+				b.WriteString(", ")
+			}
+			name := params.At(j).Name()
+			if name == "" || name == "_" || name == recvName {
+				name = fmt.Sprintf("p%d", j)
+			}
+			typ := params.At(j).Type()
+			if sig.Variadic() && j == params.Len()-1 {
+				fmt.Fprintf(&b, "%s ...%s", name, types.TypeString(typ.(*types.Slice).Elem(), qf))
+			} else {
+				fmt.Fprintf(&b, "%s %s", name, types.TypeString(typ, qf))
+			}
+		}
+		b.WriteString(")")
+		switch results := sig.Results(); results.Len() {
+		case 0:
+		case 1:
+			fmt.Fprintf(&b, " %s", types.TypeString(results.At(0).Type(), qf))
+		default:
+			b.WriteString(" (")
+			for j := 0; j < results.Len(); j++ {
+				if j > 0 {
+					b.WriteString(", ")
+				}
+				b.WriteString(types.TypeString(results.At(j).Type(), qf))
+			}
+			b.WriteString(")")
+		}
+		b.WriteString(" {\n\tpanic(\"not implemented\")\n}\n")
+	}
+
+	return b.String(), nil
+}
+
+// missingMethods returns the methods of iface that are not declared on
+// concrete, sorted by name. It is an error for concrete to declare
+// a method of iface with a different signature.
+func missingMethods(concrete types.Type, iface *types.Interface) ([]*types.Func, error) {
+	var missing []*types.Func
+	for i := 0; i < iface.NumMethods(); i++ {
+		m := iface.Method(i)
+		obj, _, _ := types.LookupFieldOrMethod(concrete, true, m.Pkg(), m.Name())
+		switch obj := obj.(type) {
+		case nil:
+			missing = append(missing, m)
+		case *types.Func:
+			if !types.Identical(obj.Type().(*types.Signature), m.Type().(*types.Signature)) {
+				return nil, fmt.Errorf("method %s of %s has signature %s, want %s", m.Name(), concrete, obj.Type(), m.Type())
+			}
+		default:
+			return nil, fmt.Errorf("%s has a field %s, conflicting with method %s", concrete, m.Name(), m.Name())
+		}
+	}
+	return missing, nil
+}
@@ -0,0 +1,66 @@
+package source
+
+import (
+	"go/types"
+	"testing"
+)
+
+func TestGenerateStub(t *testing.T) {
+	const iface = `package store
+
+import (
+	"io"
+	"time"
+)
+
+type Store interface {
+	Get(key string) (io.Reader, error)
+	Put(key string, r io.Reader, ttl time.Duration)
+	Keys(prefixes ...string) []string
+	Len() int
+}
+
+type Memory struct{}
+
+func (m *Memory) Len() int { return 0 }
+`
+	_, pkgs := loadTestPackages(t, testSource{path: "store", files: map[string]string{"store.go": iface}})
+	p := pkgs[0]
+	store := p.types.Scope().Lookup("Store").Type().Underlying().(*types.Interface)
+	memory := types.NewPointer(p.types.Scope().Lookup("Memory").Type())
+
+	stub, err := GenerateStub(memory, store, "m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `func (m *Memory) Get(key string) (io.Reader, error) {
+	panic("not implemented")
+}
+
+func (m *Memory) Keys(prefixes ...string) []string {
+	panic("not implemented")
+}
+
+func (m *Memory) Put(key string, r io.Reader, ttl time.Duration) {
+	panic("not implemented")
+}
+`
+	if stub != want {
+		t.Errorf("got stub\n%s\nwant\n%s", stub, want)
+	}
+
+	// With the stub, the concrete type implements the interface.
+	_, pkgs = loadTestPackages(t, testSource{path: "store", files: map[string]string{"store.go": iface + "\n" + stub}})
+	p = pkgs[0]
+	if len(p.errors) > 0 {
+		t.Fatalf("stub does not compile: %v", p.errors)
+	}
+	store = p.types.Scope().Lookup("Store").Type().Underlying().(*types.Interface)
+	memory = types.NewPointer(p.types.Scope().Lookup("Memory").Type())
+	if !types.Implements(memory, store) {
+		t.Error("*Memory does not implement Store with the stub")
+	}
+	if stub, err := GenerateStub(memory, store, "m"); err != nil || stub != "" {
+		t.Errorf("got stub %q, %v for a complete implementation, want none", stub, err)
+	}
+}
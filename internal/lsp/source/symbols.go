@@ -56,9 +56,21 @@ func DocumentSymbols(ctx context.Context, f GoFile) ([]Symbol, error) {
 	return getSymbols(fset, file, pkg)
 }
 
+// FileSymbols returns the outline of file, one of the syntax files of pkg:
+// its package-level functions, types, constants and variables, with the
+// fields of struct types and the methods of types declared in file nested
+// under them.
+func FileSymbols(pkg Package, fset *token.FileSet, file *ast.File) ([]Symbol, error) {
+	if pkg == nil || pkg.IsIllTyped() {
+		return nil, fmt.Errorf("package for %s is ill typed", fset.Position(file.Pos()).Filename)
+	}
+	return getSymbols(fset, file, pkg)
+}
+
 func getSymbols(fset *token.FileSet, file *ast.File, pkg Package) ([]Symbol, error) {
 	methodsToReceiver := make(map[types.Type][]Symbol)
 	symbolsToReceiver := make(map[types.Type]int)
+	var receivers []types.Type // in order of appearance
 	var symbols []Symbol
 	info := pkg.GetTypesInfo()
 	q := qualifier(file, pkg.GetTypes(), info)
@@ -69,7 +81,10 @@ func getSymbols(fset *token.FileSet, file *ast.File, pkg Package) ([]Symbol, err
 				if fs := funcSymbol(decl, obj, fset, q); fs.Kind == MethodSymbol {
 					// Store methods separately, as we want them to appear as children
 					// of the corresponding type (which we may not have seen yet).
-					rtype := obj.Type().(*types.Signature).Recv().Type()
+					rtype := receiverNamed(obj.Type().(*types.Signature).Recv().Type())
+					if _, ok := methodsToReceiver[rtype]; !ok {
+						receivers = append(receivers, rtype)
+					}
 					methodsToReceiver[rtype] = append(methodsToReceiver[rtype], fs)
 				} else {
 					symbols = append(symbols, fs)
@@ -96,11 +111,8 @@ func getSymbols(fset *token.FileSet, file *ast.File, pkg Package) ([]Symbol, err
 	}
 
 	// Attempt to associate methods to the corresponding type symbol.
-	for typ, methods := range methodsToReceiver {
-		if ptr, ok := typ.(*types.Pointer); ok {
-			typ = ptr.Elem()
-		}
-
+	for _, typ := range receivers {
+		methods := methodsToReceiver[typ]
 		if i, ok := symbolsToReceiver[typ]; ok {
 			symbols[i].Children = append(symbols[i].Children, methods...)
 		} else {
@@ -111,6 +123,19 @@ func getSymbols(fset *token.FileSet, file *ast.File, pkg Package) ([]Symbol, err
 	return symbols, nil
 }
 
+// receiverNamed returns the type declaring the methods of receiver type typ:
+// the base type of a pointer, and the generic type of an instance such as
+// T[K] in a method of a generic type.
+func receiverNamed(typ types.Type) types.Type {
+	if ptr, ok := typ.(*types.Pointer); ok {
+		typ = ptr.Elem()
+	}
+	if named, ok := typ.(*types.Named); ok {
+		return named.Origin()
+	}
+	return typ
+}
+
 func funcSymbol(decl *ast.FuncDecl, obj types.Object, fset *token.FileSet, q types.Qualifier) Symbol {
 	s := Symbol{
 		Name: obj.Name(),
@@ -2,7 +2,12 @@ package source
 
 import (
 	"context"
+	"go/token"
+	"go/types"
+	"sort"
 	"strings"
+
+	"golang.org/x/tools/internal/lsp/fuzzy"
 )
 
 func Symbols(ctx context.Context, view View, query string, limit int) []Symbol {
@@ -32,3 +37,110 @@ func Symbols(ctx context.Context, view View, query string, limit int) []Symbol {
 	view.Search()(f)
 	return symbols
 }
+
+// WorkspaceSymbolOptions configures WorkspaceSymbols.
+type WorkspaceSymbolOptions struct {
+	// Limit caps the number of results, if positive.
+	Limit int
+	// Unexported includes unexported names.
+	Unexported bool
+}
+
+// WorkspaceSymbol is a symbol matching a workspace symbol query.
+type WorkspaceSymbol struct {
+	Name string
+	Kind SymbolKind
+	// PkgPath is the path of the declaring package.
+	PkgPath string
+	// Score is the fuzzy match score of Name, between 0 and 1.
+	Score    float32
+	Location Location
+}
+
+// WorkspaceSymbols returns the package-level declarations, methods and
+// fields across the cache whose names fuzzily match query, best matches
+// first.
+func WorkspaceSymbols(c ICache, fset *token.FileSet, query string, opts WorkspaceSymbolOptions) []WorkspaceSymbol {
+	type candidate struct {
+		pkg   Package
+		obj   types.Object
+		score float32
+	}
+	var candidates []candidate
+	matcher := fuzzy.NewMatcher(query, fuzzy.Symbol)
+	c.Walk(func(p Package) bool {
+		info := p.GetTypesInfo()
+		if info == nil || p.GetTypes() == nil {
+			return false
+		}
+		scope := p.GetTypes().Scope()
+		for _, obj := range info.Defs {
+			if obj == nil || !opts.Unexported && !obj.Exported() {
+				continue
+			}
+			switch obj := obj.(type) {
+			case *types.Func:
+			case *types.Var:
+				if !obj.IsField() && obj.Parent() != scope {
+					continue
+				}
+			case *types.TypeName, *types.Const:
+				if obj.Parent() != scope {
+					continue
+				}
+			default:
+				continue
+			}
+			if score := matcher.Score(obj.Name()); score > 0 {
+				candidates = append(candidates, candidate{p, obj, score})
+			}
+		}
+		return false
+	})
+
+	sort.Slice(candidates, func(i, j int) bool {
+		ci, cj := candidates[i], candidates[j]
+		if ci.score != cj.score {
+			return ci.score > cj.score
+		}
+		if ci.obj.Name() != cj.obj.Name() {
+			return ci.obj.Name() < cj.obj.Name()
+		}
+		return ci.obj.Pos() < cj.obj.Pos()
+	})
+	if opts.Limit > 0 && len(candidates) > opts.Limit {
+		candidates = candidates[:opts.Limit]
+	}
+
+	var symbols []WorkspaceSymbol
+	for _, cand := range candidates {
+		_, ident, err := getObjectPathNode(cand.pkg, fset, cand.obj)
+		if err != nil {
+			continue
+		}
+		symbols = append(symbols, WorkspaceSymbol{
+			Name:     cand.obj.Name(),
+			Kind:     objectSymbolKind(cand.obj),
+			PkgPath:  cand.obj.Pkg().Path(),
+			Score:    cand.score,
+			Location: toLocation(fset, ident.Pos(), ident.Name),
+		})
+	}
+	return symbols
+}
+
+// objectSymbolKind returns the kind of symbol declared by obj.
+func objectSymbolKind(obj types.Object) SymbolKind {
+	switch obj := obj.(type) {
+	case *types.Const:
+		return ConstantSymbol
+	case *types.Var:
+		if obj.IsField() {
+			return FieldSymbol
+		}
+		return VariableSymbol
+	}
+	var s Symbol
+	setKind(&s, obj.Type(), nil)
+	return s.Kind
+}
@@ -0,0 +1,137 @@
+package source
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// outline renders symbols as "name:kind", with children in parentheses.
+func outline(symbols []Symbol) string {
+	var parts []string
+	for _, s := range symbols {
+		part := fmt.Sprintf("%s:%v", s.Name, s.Kind)
+		if len(s.Children) > 0 {
+			part += "(" + outline(s.Children) + ")"
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestFileSymbols(t *testing.T) {
+	fset, pkgs := loadTestPackages(t, testSource{path: "outline", files: map[string]string{
+		"outline.go": `package outline
+
+const Max = 10
+
+var count int
+
+type Point struct {
+	X, Y int
+}
+
+func (p *Point) Move() {}
+
+type Shape interface {
+	Area() float64
+}
+
+type List[T any] struct{ items []T }
+
+func (l *List[T]) Len() int { return len(l.items) }
+
+func (p Point) String() string { return "" }
+
+func New() *Point { return &Point{} }
+
+func (Other) Elsewhere() {}
+`,
+		"other.go": `package outline
+
+type Other struct{}
+`,
+	}})
+	p := pkgs[0]
+
+	// Files are loaded in name order.
+	symbols, err := FileSymbols(p, fset, p.syntax[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := fmt.Sprintf("Max:%v count:%v Point:%v(X:%v Y:%v Move:%v String:%v) Shape:%v(Area:%v) List:%v(items:%v Len:%v) New:%v Elsewhere:%v",
+		ConstantSymbol, VariableSymbol,
+		StructSymbol, FieldSymbol, FieldSymbol, MethodSymbol, MethodSymbol,
+		InterfaceSymbol, MethodSymbol,
+		StructSymbol, FieldSymbol, MethodSymbol,
+		FunctionSymbol, MethodSymbol)
+	if got := outline(symbols); got != want {
+		t.Errorf("got outline\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWorkspaceSymbols(t *testing.T) {
+	fset, pkgs := loadTestPackages(t,
+		testSource{path: "config", files: map[string]string{"config.go": `package config
+
+type Config struct {
+	Path string
+}
+
+func Parse(path string) (*Config, error) { return parseFile(path) }
+
+func ParseConfig(data []byte) *Config { return nil }
+
+func parseFile(path string) (*Config, error) {
+	var parsed Config
+	return &parsed, nil
+}
+
+const DefaultPath = "config.yaml"
+`}},
+		testSource{path: "app", files: map[string]string{"app.go": `package app
+
+import "config"
+
+var Loaded, _ = config.Parse(config.DefaultPath)
+
+func (a App) Reparse() {}
+
+type App struct{}
+`}},
+	)
+	c := testCache{pkgs[0], pkgs[1]}
+
+	names := func(query string, opts WorkspaceSymbolOptions) string {
+		var names []string
+		for _, s := range WorkspaceSymbols(c, fset, query, opts) {
+			names = append(names, s.PkgPath+"."+s.Name)
+			if text := testLocationText(t, pkgs, s.Location); text != s.Name {
+				t.Errorf("%s: location spans %q", s.Name, text)
+			}
+		}
+		return strings.Join(names, " ")
+	}
+
+	for _, test := range []struct {
+		query string
+		opts  WorkspaceSymbolOptions
+		want  string
+	}{
+		// An exact match ranks first; locals such as parsed are never listed.
+		{"Parse", WorkspaceSymbolOptions{}, "config.Parse config.ParseConfig app.Reparse"},
+		{"Par", WorkspaceSymbolOptions{Limit: 2}, "config.Parse config.ParseConfig"},
+		{"pconf", WorkspaceSymbolOptions{}, "config.ParseConfig"},
+		{"parse", WorkspaceSymbolOptions{Unexported: true}, "config.Parse config.ParseConfig config.parseFile app.Reparse"},
+		{"path", WorkspaceSymbolOptions{}, "config.Path config.DefaultPath"},
+	} {
+		if got := names(test.query, test.opts); got != test.want {
+			t.Errorf("WorkspaceSymbols(%q, %+v) = %s, want %s", test.query, test.opts, got, test.want)
+		}
+	}
+
+	symbols := WorkspaceSymbols(c, fset, "Config", WorkspaceSymbolOptions{Limit: 1})
+	if len(symbols) != 1 || symbols[0].Kind != StructSymbol || symbols[0].Score != 1 {
+		t.Errorf("got %+v, want the Config struct as a perfect match", symbols)
+	}
+}